@@ -0,0 +1,106 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Ogurczak/discord-usos-auth/usos"
+)
+
+// verification represents a discord account's successful usos verification on a guild.
+// Only the usos id is kept, the rest of the usos data is remembered only for users who consented to linking it.
+type verification struct {
+	UsosID     string
+	VerifiedAt time.Time
+	ExpiresAt  time.Time // end of the user's last active term, zero if verification never expires
+	ExpiredAt  time.Time // when the member was found not qualifying anymore, zero if he still does
+	Stage      memberStage
+	StageSince time.Time
+}
+
+// accountPolicy decides what happens when an usos account verifies another discord account on the same guild
+type accountPolicy string
+
+const (
+	// accountPolicyAllow lets many discord accounts verify with the same usos account
+	accountPolicyAllow accountPolicy = "allow"
+	// accountPolicyDeny refuses verification of another discord account
+	accountPolicyDeny accountPolicy = "deny"
+	// accountPolicyMove verifies the new discord account and deauthorizes the old one
+	accountPolicyMove accountPolicy = "move"
+)
+
+var accountPolicies = []string{string(accountPolicyAllow), string(accountPolicyDeny), string(accountPolicyMove)}
+
+// recordVerification stores the usos account which verified the user on the given guild
+func (bot *UsosBot) recordVerification(guildID string, userID string, usosUser *usos.User) {
+	guildInfo := bot.getGuildUsosInfo(guildID)
+	now := time.Now()
+	grace := time.Duration(guildInfo.Reverification.GraceDays) * 24 * time.Hour
+	guildInfo.VerifiedMembers[userID] = &verification{
+		UsosID:     usosUser.ID,
+		VerifiedAt: now,
+		ExpiresAt:  verificationExpiry(usosUser, grace, now),
+	}
+}
+
+// verificationExpiry returns when the usos user's verification expires, the end of his last term.
+// Usos keeps terms active for a while after they end and users may have no terms at all,
+// so verifications not expiring in the future are checked again after the grace period, at least a day later.
+func verificationExpiry(usosUser *usos.User, grace time.Duration, now time.Time) time.Time {
+	var expiresAt time.Time
+	for _, term := range usosUser.Terms {
+		if term.EndDate.After(expiresAt) {
+			expiresAt = term.EndDate
+		}
+	}
+	if expiresAt.After(now) {
+		return expiresAt
+	}
+	if grace < minReverificationDelay {
+		grace = minReverificationDelay
+	}
+	return now.Add(grace)
+}
+
+// verifiedAccount returns id of another discord account verified on the guild with the given usos account
+// or an empty string if there is none
+func (bot *UsosBot) verifiedAccount(guildID string, userID string, usosID string) string {
+	for verifiedUserID, verification := range bot.getGuildUsosInfo(guildID).VerifiedMembers {
+		if verifiedUserID != userID && verification.UsosID == usosID {
+			return verifiedUserID
+		}
+	}
+	return ""
+}
+
+// enforceAccountPolicy applies the guild's account policy before verifying the user with the given usos account
+func (bot *UsosBot) enforceAccountPolicy(guildID string, userID string, usosID string) error {
+	bot.mu.Lock()
+	otherUserID := bot.verifiedAccount(guildID, userID, usosID)
+	policy := bot.getGuildUsosInfo(guildID).AccountPolicy
+	bot.mu.Unlock()
+	if otherUserID == "" {
+		return nil
+	}
+
+	switch policy {
+	case accountPolicyDeny:
+		err := bot.logDiscord(guildID, fmt.Sprintf(
+			"<@%s> was denied verification with the usos account already verifying <@%s>", userID, otherUserID))
+		if err != nil {
+			return err
+		}
+		return newErrUsosAccountInUse(userID, otherUserID)
+	case accountPolicyMove:
+		err := bot.deauthorizeMember(guildID, otherUserID)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+		return bot.logDiscord(guildID, fmt.Sprintf(
+			"Verification with usos account was moved from <@%s> to <@%s>", otherUserID, userID))
+	default:
+		return bot.logDiscord(guildID, fmt.Sprintf(
+			"<@%s> was verified with the usos account already verifying <@%s>", userID, otherUserID))
+	}
+}
@@ -3,6 +3,7 @@ package bot
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/url"
 	"time"
 
@@ -13,39 +14,134 @@ import (
 )
 
 // addUnauthorizedMember creates a new oauth token bound to the given member
-// and sends authorization instructions to that member
-func (bot *UsosBot) addUnauthorizedMember(m *discordgo.Member) error {
-	if bot.tokenMap[m.User.ID] != nil {
-		return newErrAlreadyRegistered(m.User.ID, bot.tokenMap[m.User.ID])
+// and sends authorization instructions to that member, preceded by the description if it is not empty
+func (bot *UsosBot) addUnauthorizedMember(m *discordgo.Member, description string) error {
+	authorizationURL, err := bot.registerUnauthorizedMember(m)
+	if err != nil {
+		return err
+	}
+	return bot.sendAuthorizationInstructions(m, authorizationURL, description)
+}
+
+// registerUnauthorizedMember creates a new oauth token bound to the given member,
+// returns the url the member authorizes the token at
+func (bot *UsosBot) registerUnauthorizedMember(m *discordgo.Member) (*url.URL, error) {
+	bot.mu.Lock()
+	pair := bot.tokenMap[m.User.ID][m.GuildID]
+	bot.mu.Unlock()
+	if pair != nil {
+		return nil, newErrAlreadyRegistered(m.User.ID, pair)
 	}
 	token, err := usos.NewRequestToken()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+	// the member might have registered while the token was being created
+	if pair := bot.tokenMap[m.User.ID][m.GuildID]; pair != nil {
+		return nil, newErrAlreadyRegistered(m.User.ID, pair)
+	}
+	if bot.tokenMap[m.User.ID] == nil {
+		bot.tokenMap[m.User.ID] = make(map[string]*requestTokenGuildPair)
+	}
+	now := time.Now()
+	bot.tokenMap[m.User.ID][m.GuildID] = &requestTokenGuildPair{
+		RequestToken:  token,
+		GuildID:       m.GuildID,
+		RegisteredAt:  now,
+		TokenIssuedAt: now}
+	return token.AuthorizationURL, nil
+}
+
+// renewRequestToken replaces the request token of the user's pending verification on the guild
+// with a new one if it has expired, returns the url the current token is authorized at
+func (bot *UsosBot) renewRequestToken(userID string, guildID string) (*url.URL, error) {
+	bot.mu.Lock()
+	pair := bot.tokenMap[userID][guildID]
+	if pair == nil {
+		bot.mu.Unlock()
+		return nil, newErrUnregisteredUserNotFound(userID)
+	}
+	if time.Since(pair.TokenIssuedAt) < bot.requestTokenLifetime() {
+		defer bot.mu.Unlock()
+		return pair.RequestToken.AuthorizationURL, nil
 	}
-	bot.tokenMap[m.User.ID] = &requestTokenGuildPair{
-		RequestToken: token,
-		GuildID:      m.GuildID}
+	bot.mu.Unlock()
 
-	err = bot.sendAuthorizationInstructions(m, token.AuthorizationURL)
+	token, err := usos.NewRequestToken()
 	if err != nil {
+		return nil, err
+	}
+	bot.mu.Lock()
+	pair.RequestToken = token
+	pair.TokenIssuedAt = time.Now()
+	bot.mu.Unlock()
+	return token.AuthorizationURL, nil
+}
+
+// startAuthorization authorizes the member with his linked identity if the guild allows it,
+// otherwise registers him for authorization and sends him instructions preceded by the description.
+// Authorized members are ignored.
+func (bot *UsosBot) startAuthorization(member *discordgo.Member, description string) error {
+	authorized, err := bot.isAuthorized(member)
+	if err != nil || authorized {
+		return err
+	}
+
+	bot.mu.Lock()
+	reuse := bot.canReuseIdentity(member.GuildID, member.User.ID)
+	bot.mu.Unlock()
+	if reuse {
+		err = bot.authorizeWithIdentity(member)
+		switch err.(type) {
+		case nil:
+			return bot.msgMemberText(member, bot.authorizationSummary(map[string]error{member.GuildID: nil}))
+		case *ErrFilteredOut, *ErrUsosAccountInUse, *ErrGraduated:
+			return bot.msgMemberText(member, err.Error())
+		case *ErrIdentityStale:
+			// fall back to regular authorization
+		default:
+			return err
+		}
+	}
+
+	err = bot.addUnauthorizedMember(member, description)
+	switch err.(type) {
+	case *ErrAlreadyRegistered:
+		return bot.msgMemberText(member, "Already registered for verification")
+	default:
 		return err
 	}
-	return nil
 }
 
-// removeUnauthorizedUser removes an user from authorization list
-func (bot *UsosBot) removeUnauthorizedUser(userID string) error {
-	if _, exists := bot.tokenMap[userID]; !exists {
+// removeUnauthorizedUser removes an user from authorization list of the given guild,
+// or from authorization lists of all guilds if guildID is empty
+func (bot *UsosBot) removeUnauthorizedUser(userID string, guildID string) error {
+	pairs := bot.tokenMap[userID]
+	if guildID == "" {
+		if len(pairs) == 0 {
+			return newErrUnregisteredUserNotFound(userID)
+		}
+		delete(bot.tokenMap, userID)
+		return nil
+	}
+
+	if _, exists := pairs[guildID]; !exists {
 		return newErrUnregisteredUserNotFound(userID)
 	}
-	delete(bot.tokenMap, userID)
+	delete(pairs, guildID)
+	if len(pairs) == 0 {
+		delete(bot.tokenMap, userID)
+	}
 	return nil
 }
 
-// authorizeMember authorizes the given member and gives him additional roles based on bot's roles function
+// authorizeMember authorizes the given member and gives him additional roles based on the guild's role rules,
+// role rules no longer matching the usos user have their roles taken away.
+// Sets the member's nickname if the guild has a nickname template.
 func (bot *UsosBot) authorizeMember(member *discordgo.Member, usosUser *usos.User) error {
-	var roles []*discordgo.Role
-
 	authorizeRole, err := bot.getAuthorizeRole(member.GuildID)
 	if err != nil {
 		if IsNotFound(err) {
@@ -58,50 +154,131 @@ func (bot *UsosBot) authorizeMember(member *discordgo.Member, usosUser *usos.Use
 		}
 	}
 
-	// TODO: automatic roles assignment
-	// if bot.UserRolesFunction != nil && usosUser != nil {
-	// 	roles, err = bot.UserRolesFunction(usosUser)
-	// 	if err != nil {
-	// 		return err
-	// 	}
-	// }
-	roles = append(roles, authorizeRole)
+	roleIDs := map[string]bool{authorizeRole.ID: true}
+	staleRoleIDs := map[string]bool{}
+	if usosUser != nil {
+		bot.mu.Lock()
+		matched, unmatched, err := bot.ruleRoles(member.GuildID, usosUser)
+		bot.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		for roleID := range matched {
+			roleIDs[roleID] = true
+		}
+		staleRoleIDs = unmatched
+	}
 
-	for _, role := range roles {
-		err = bot.GuildMemberRoleAdd(member.GuildID, member.User.ID, role.ID)
+	for roleID := range roleIDs {
+		err = bot.GuildMemberRoleAdd(member.GuildID, member.User.ID, roleID)
 		if err != nil {
 			return err
 		}
 	}
+	for _, roleID := range member.Roles {
+		if staleRoleIDs[roleID] {
+			err = bot.GuildMemberRoleRemove(member.GuildID, member.User.ID, roleID)
+			if err != nil {
+				return err
+			}
+		}
+	}
 
+	bot.applyNicknameOrReport(member, usosUser)
 	return nil
 }
 
-// sendAuthorizationInstructions sends instructions on authorization to the given member
-func (bot *UsosBot) sendAuthorizationInstructions(member *discordgo.Member, tokenURL *url.URL) error {
-	time.Sleep(time.Second)
-	channel, err := bot.UserChannelCreate(member.User.ID)
+// deauthorizeMember takes the authorize role and roles given by role rules away from the member
+// and forgets his verification and the authorization granted to him
+func (bot *UsosBot) deauthorizeMember(guildID string, userID string) error {
+	bot.mu.Lock()
+	guildInfo := bot.getGuildUsosInfo(guildID)
+	delete(guildInfo.VerifiedMembers, userID)
+	delete(guildInfo.Grants, userID)
+	bot.mu.Unlock()
+	return bot.removeAuthorizationRoles(guildID, userID)
+}
+
+// removeAuthorizationRoles takes the authorize role and roles given by role rules away from the member
+func (bot *UsosBot) removeAuthorizationRoles(guildID string, userID string) error {
+	bot.mu.Lock()
+	ruleRoleIDs := make([]string, 0)
+	for _, rule := range bot.getGuildUsosInfo(guildID).RoleRules {
+		ruleRoleIDs = append(ruleRoleIDs, rule.RoleID)
+	}
+	bot.mu.Unlock()
+
+	for _, roleID := range ruleRoleIDs {
+		err := bot.GuildMemberRoleRemove(guildID, userID, roleID)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+	}
+
+	authorizeRole, err := bot.getAuthorizeRole(guildID)
 	if err != nil {
 		return err
 	}
+	return bot.GuildMemberRoleRemove(guildID, userID, authorizeRole.ID)
+}
+
+// requestReverification takes the member's authorization away and asks him to verify again
+func (bot *UsosBot) requestReverification(member *discordgo.Member, description string) error {
+	err := bot.deauthorizeMember(member.GuildID, member.User.ID)
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+	err = bot.addUnauthorizedMember(member, description)
+	if _, ok := err.(*ErrAlreadyRegistered); ok {
+		return nil
+	}
+	return err
+}
+
+// guildMember returns the guild's member with the given ID
+func (bot *UsosBot) guildMember(guildID string, userID string) (*discordgo.Member, error) {
+	member, err := bot.GuildMember(guildID, userID)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, newErrMemberNotFound(err, userID, guildID)
+		}
+		return nil, err
+	}
+	member.GuildID = guildID // because for some reason its empty (?)
+	return member, nil
+}
+
+// sendAuthorizationInstructions sends instructions on authorization to the given member
+func (bot *UsosBot) sendAuthorizationInstructions(member *discordgo.Member, tokenURL *url.URL, description string) error {
+	bot.mu.Lock()
+	verifyUsage := bot.commandSpan(member.GuildID, "verify -c <verifier>")
+	abortUsage := bot.commandSpan(member.GuildID, "verify -a")
+	bot.mu.Unlock()
+
 	msg := &discordgo.MessageEmbed{
-		Type:  discordgo.EmbedTypeRich,
-		URL:   tokenURL.String(),
-		Title: "USOS Authorization required",
+		Type:        discordgo.EmbedTypeRich,
+		URL:         tokenURL.String(),
+		Title:       "USOS Authorization required",
+		Description: description,
 		Fields: []*discordgo.MessageEmbedField{
 			{
 				Name: "You must authorize yourself before proceeding on this server.",
 				Value: fmt.Sprintf(`In order to do that visit [this page](%s) and authorize.
-				After that send me the authorization verifier using the %s command.
-				You can also abort the authorization process using the %s command.`,
-					tokenURL, utils.DiscordCodeSpan("!usos verify -c <verifier>"),
-					utils.DiscordCodeSpan("!usos verify -a")),
+				After that click the button below and enter the authorization verifier,
+				or send it to me using the %s command.
+				You can also abort the authorization process using the %s command.
+				Add %s to the verify command to skip this process on other servers that allow it.`,
+					tokenURL, verifyUsage, abortUsage, utils.DiscordCodeSpan("-r")),
 				Inline: true,
 			},
 		},
 	}
-	_, err = bot.ChannelMessageSendEmbed(channel.ID, msg)
-	return err
+	return bot.msgMember(member, &discordgo.MessageSend{
+		Embed: msg,
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{enterCodeButton()}},
+		},
+	})
 }
 
 // createAuthorizeRole creates an authorize role in the given guild
@@ -114,8 +291,9 @@ func (bot *UsosBot) createAuthorizeRole(GuildID string) (*discordgo.Role, error)
 	if err != nil {
 		return nil, err
 	}
-	guildInfo := bot.getGuildUsosInfo(GuildID)
-	guildInfo.AuthorizeRoleID = authorizeRole.ID
+	bot.mu.Lock()
+	bot.getGuildUsosInfo(GuildID).AuthorizeRoleID = authorizeRole.ID
+	bot.mu.Unlock()
 	return authorizeRole, nil
 }
 
@@ -134,16 +312,23 @@ func (bot *UsosBot) guildRole(GuildID string, RoleID string) (*discordgo.Role, e
 
 // getAuthorizeRole return authorization role id of the given guild
 func (bot *UsosBot) getAuthorizeRole(GuildID string) (*discordgo.Role, error) {
-	guildInfo := bot.getGuildUsosInfo(GuildID)
-	if guildInfo.AuthorizeRoleID == "" {
+	bot.mu.Lock()
+	roleID := bot.getGuildUsosInfo(GuildID).AuthorizeRoleID
+	bot.mu.Unlock()
+	if roleID == "" {
 		return nil, newErrAuthorizeRoleNotFound(GuildID)
 	}
 
-	role, err := bot.guildRole(GuildID, guildInfo.AuthorizeRoleID)
+	role, err := bot.guildRole(GuildID, roleID)
 	if err != nil {
 		if IsNotFound(err) {
 			// role was deleted
-			guildInfo.AuthorizeRoleID = ""
+			bot.mu.Lock()
+			guildInfo := bot.getGuildUsosInfo(GuildID)
+			if guildInfo.AuthorizeRoleID == roleID {
+				guildInfo.AuthorizeRoleID = ""
+			}
+			bot.mu.Unlock()
 		}
 		return nil, err
 	}
@@ -169,12 +354,17 @@ func (bot *UsosBot) isAuthorized(member *discordgo.Member) (bool, error) {
 }
 
 // spawnAuthorizeMessage spawns a messege in a given channel
-// which adds unauthorized members if reacted to
+// which starts authorization of members who click its button or react to it
 func (bot *UsosBot) spawnAuthorizeMessage(GuildID string, ChannelID string, prompt string) error {
-	msg, err := bot.ChannelMessageSend(ChannelID, prompt)
+	msg, err := bot.ChannelMessageSendComplex(ChannelID, &discordgo.MessageSend{
+		Content:    prompt,
+		Components: verifyButton(),
+	})
 	if err != nil {
 		return err
 	}
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
 	guildInfo := bot.getGuildUsosInfo(GuildID)
 	if guildInfo.AuthorizeMessegeIDs[ChannelID] == nil {
 		guildInfo.AuthorizeMessegeIDs[ChannelID] = make(map[string]bool)
@@ -183,16 +373,21 @@ func (bot *UsosBot) spawnAuthorizeMessage(GuildID string, ChannelID string, prom
 	return nil
 }
 
-func (bot *UsosBot) authorizeWithToken(guildID string, user *discordgo.User, token *oauth1.Token) error {
+// fetchUsosUser downloads the usos user's data required for authorization
+func fetchUsosUser(token *oauth1.Token) (*usos.User, error) {
 	usosUser, err := usos.NewUsosUser(token)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	_, err = usosUser.GetCoursesLight(true)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return usosUser, nil
+}
 
+// authorizeWithUsosUser authorizes the user on the given guild if his usos data passes the guild's filters
+func (bot *UsosBot) authorizeWithUsosUser(guildID string, user *discordgo.User, usosUser *usos.User) error {
 	message, err := json.MarshalIndent(usosUser, "", "  ")
 	if err != nil {
 		return err
@@ -202,68 +397,161 @@ func (bot *UsosBot) authorizeWithToken(guildID string, user *discordgo.User, tok
 		return err
 	}
 
-	match, err := bot.filter(guildID, usosUser)
+	member, err := bot.GuildMember(guildID, user.ID)
 	if err != nil {
 		return err
 	}
-	if !match {
-		err := bot.removeUnauthorizedUser(user.ID)
+	member.GuildID = guildID // because for some reason its empty (?)
+
+	bot.mu.Lock()
+	report, errBlocked := bot.rejectBlocked(guildID, user.ID, usosUser.ID)
+	if errBlocked != nil {
+		bot.removeUnauthorizedUser(user.ID, guildID)
+	}
+	bot.mu.Unlock()
+	if errBlocked != nil {
+		err = bot.logDiscord(guildID, report)
 		if err != nil {
 			return err
 		}
+		return errBlocked
+	}
+
+	transitioned, err := bot.applyLifecycle(member, usosUser)
+	if err != nil {
+		return err
+	}
+	if transitioned {
+		bot.mu.Lock()
+		bot.removeUnauthorizedUser(user.ID, guildID)
+		bot.mu.Unlock()
+		return newErrGraduated(user.ID)
+	}
+
+	bot.mu.Lock()
+	match, err := bot.filter(guildID, usosUser)
+	if err == nil && !match {
+		bot.removeUnauthorizedUser(user.ID, guildID) // may be not registered if authorized with a linked identity
+	}
+	bot.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if !match {
 		return newErrFilteredOut(user.ID)
 	}
 
-	member, err := bot.GuildMember(guildID, user.ID)
+	err = bot.enforceAccountPolicy(guildID, user.ID, usosUser.ID)
 	if err != nil {
+		bot.mu.Lock()
+		bot.removeUnauthorizedUser(user.ID, guildID)
+		bot.mu.Unlock()
 		return err
 	}
-	member.GuildID = guildID // because for some reason its empty (?)
 
 	err = bot.authorizeMember(member, usosUser)
 	if err != nil {
 		return err
 	}
-	delete(bot.tokenMap, user.ID)
+	bot.mu.Lock()
+	bot.recordVerification(guildID, user.ID, usosUser)
+	bot.removeUnauthorizedUser(user.ID, guildID)
+	bot.mu.Unlock()
 
+	bot.provisionCoursesOrReport(guildID, user.ID, usosUser)
 	return nil
 }
 
-// finalizeAuthorization finalizes the user's authorization using the given verifier
-func (bot *UsosBot) finalizeAuthorization(user *discordgo.User, verifier string) error {
-	tokenGuilIDPair := bot.tokenMap[user.ID]
-	if tokenGuilIDPair == nil {
-		return newErrUnregisteredUnauthorizedUser(user.ID)
+// finalizeAuthorization finalizes the user's authorization using the given verifier.
+// The verifier is matched against all of the user's pending requests and, once matched,
+// the obtained usos data is used to authorize the user on every guild he requested.
+// Returns the authorization result for each of these guilds.
+func (bot *UsosBot) finalizeAuthorization(user *discordgo.User, verifier string) (map[string]error, error) {
+	bot.mu.Lock()
+	requestTokens := make(map[string]*usos.RequestToken, len(bot.tokenMap[user.ID]))
+	for guildID, pair := range bot.tokenMap[user.ID] {
+		requestTokens[guildID] = pair.RequestToken
+	}
+	bot.mu.Unlock()
+	if len(requestTokens) == 0 {
+		return nil, newErrUnregisteredUnauthorizedUser(user.ID)
 	}
 
-	accessToken, err := tokenGuilIDPair.RequestToken.GetAccessToken(verifier)
-	if err != nil {
-		return newErrWrongVerifier(err, user.ID, tokenGuilIDPair, verifier)
+	var accessToken *oauth1.Token
+	var err error
+	for _, requestToken := range requestTokens {
+		accessToken, err = requestToken.GetAccessToken(verifier)
+		if err == nil {
+			break
+		}
+	}
+	if accessToken == nil {
+		return nil, newErrWrongVerifier(err, user.ID, verifier)
 	}
 
-	err = bot.authorizeWithToken(tokenGuilIDPair.GuildID, user, accessToken)
+	usosUser, err := fetchUsosUser(accessToken)
 	switch err.(type) {
+	case nil:
+		// no-op
 	case *usos.ErrUnableToCall:
-		return newErrWrongVerifier(err, user.ID, tokenGuilIDPair, verifier)
+		return nil, newErrWrongVerifier(err, user.ID, verifier)
 	default:
-		return err
+		return nil, err
+	}
+	bot.mu.Lock()
+	bot.updateIdentity(user.ID, usosUser, accessToken)
+	bot.mu.Unlock()
+
+	results := make(map[string]error, len(requestTokens))
+	for guildID := range requestTokens {
+		results[guildID] = bot.authorizeWithUsosUser(guildID, user, usosUser)
 	}
+	return results, nil
+}
+
+// authorizationSummary describes the results of finalizeAuthorization to the user
+func (bot *UsosBot) authorizationSummary(results map[string]error) string {
+	msg := ""
+	for guildID, err := range results {
+		name := guildID
+		guild, guildErr := bot.Guild(guildID)
+		if guildErr == nil {
+			name = guild.Name
+		}
+		msg += utils.DiscordBold(name) + ": "
+		switch err.(type) {
+		case nil:
+			msg += "Authorization complete"
+		case *ErrFilteredOut, *ErrRoleNotFound, *ErrUsosAccountInUse, *ErrGraduated:
+			msg += err.Error()
+		default:
+			log.Println(err)
+			msg += "Authorization failed, consult server administrators for details."
+		}
+		msg += "\n"
+	}
+	return msg
 }
 
 // filter checks if an usos user passes at least one of the set filters
 func (bot *UsosBot) filter(guildID string, user *usos.User) (bool, error) {
-	guildInfo := bot.getGuildUsosInfo(guildID)
-	if len(guildInfo.Filters) == 0 {
+	if len(bot.getGuildUsosInfo(guildID).Filters) == 0 {
 		return true, nil
 	}
-	for _, filter := range guildInfo.Filters {
+	i, err := bot.matchingFilter(guildID, user)
+	return i >= 0, err
+}
+
+// matchingFilter returns the index of the guild's first filter matching the usos user, -1 if none matches
+func (bot *UsosBot) matchingFilter(guildID string, user *usos.User) (int, error) {
+	for i, filter := range bot.getGuildUsosInfo(guildID).Filters {
 		match, err := utils.FilterRec(filter, user)
 		if err != nil {
-			return false, err
+			return -1, err
 		}
 		if match {
-			return true, nil
+			return i, nil
 		}
 	}
-	return false, nil
+	return -1, nil
 }
@@ -0,0 +1,77 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+)
+
+// block represents an usos account blocked from verifying on a guild
+type block struct {
+	BlockedBy string
+	Reason    string
+	BlockedAt time.Time
+}
+
+// isBlocked checks if the usos account is blocked on the guild
+func (bot *UsosBot) isBlocked(guildID string, usosID string) bool {
+	return bot.getGuildUsosInfo(guildID).BlockedUsosIDs[usosID] != nil
+}
+
+// rejectBlocked returns the report of the user's attempt to verify with a blocked usos account and the error
+// told to him, which does not reveal the block, or a nil error if the account is not blocked
+func (bot *UsosBot) rejectBlocked(guildID string, userID string, usosID string) (string, error) {
+	if !bot.isBlocked(guildID, usosID) {
+		return "", nil
+	}
+	return fmt.Sprintf("<@%s> tried to verify with blocked usos account %s", userID, usosID),
+		newErrFilteredOut(userID)
+}
+
+// blockUsosID blocks the usos account from verifying on the guild
+// and deauthorizes members verified with it
+func (bot *UsosBot) blockUsosID(guildID string, usosID string, blockedBy string, reason string) error {
+	bot.mu.Lock()
+	guildInfo := bot.getGuildUsosInfo(guildID)
+	guildInfo.BlockedUsosIDs[usosID] = &block{
+		BlockedBy: blockedBy,
+		Reason:    reason,
+		BlockedAt: time.Now(),
+	}
+	userIDs := make([]string, 0)
+	for userID, verification := range guildInfo.VerifiedMembers {
+		if verification.UsosID == usosID {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	bot.mu.Unlock()
+
+	for _, userID := range userIDs {
+		err := bot.deauthorizeMember(guildID, userID)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+		err = bot.logDiscord(guildID, fmt.Sprintf("<@%s> was deauthorized, his usos account was blocked", userID))
+		if err != nil {
+			return err
+		}
+	}
+
+	msg := fmt.Sprintf("<@%s> blocked usos account %s", blockedBy, usosID)
+	if reason != "" {
+		msg += ", reason: " + reason
+	}
+	return bot.logDiscord(guildID, msg)
+}
+
+// unblockUsosID lets the usos account verify on the guild again
+func (bot *UsosBot) unblockUsosID(guildID string, usosID string, unblockedBy string) error {
+	bot.mu.Lock()
+	guildInfo := bot.getGuildUsosInfo(guildID)
+	blocked := guildInfo.BlockedUsosIDs[usosID] != nil
+	delete(guildInfo.BlockedUsosIDs, usosID)
+	bot.mu.Unlock()
+	if !blocked {
+		return newErrBlockNotFound(usosID)
+	}
+	return bot.logDiscord(guildID, fmt.Sprintf("<@%s> unblocked usos account %s", unblockedBy, usosID))
+}
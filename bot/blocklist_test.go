@@ -0,0 +1,37 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRejectBlocked(t *testing.T) {
+	bot := &UsosBot{guildUsosInfos: map[string]*guildUsosInfo{
+		"guildID": {BlockedUsosIDs: map[string]*block{"blockedID": {BlockedBy: "adminID", Reason: "ban evasion"}}},
+	}}
+
+	tests := []struct {
+		guildID string
+		usosID  string
+		blocked bool
+	}{
+		{"guildID", "blockedID", true},
+		{"guildID", "otherID", false},
+		{"otherGuildID", "blockedID", false},
+	}
+	for _, test := range tests {
+		report, err := bot.rejectBlocked(test.guildID, "userID", test.usosID)
+		if !test.blocked {
+			assert.NoError(t, err, test)
+			assert.Empty(t, report, test)
+			continue
+		}
+
+		// the user is told he does not meet the requirements, only the log channels learn about the block
+		assert.IsType(t, &ErrFilteredOut{}, err, test)
+		assert.NotContains(t, err.Error(), "block", test)
+		assert.Contains(t, report, "<@userID>", test)
+		assert.Contains(t, report, test.usosID, test)
+	}
+}
@@ -3,16 +3,22 @@ package bot
 import (
 	"encoding/json"
 	"io"
-	"log"
+	"sync"
+	"time"
 
+	"github.com/Ogurczak/discord-usos-auth/bot/commands"
 	"github.com/Ogurczak/discord-usos-auth/usos"
 
 	"github.com/bwmarrin/discordgo"
 )
 
 type requestTokenGuildPair struct {
-	GuildID      string
-	RequestToken *usos.RequestToken
+	GuildID       string
+	RequestToken  *usos.RequestToken
+	RegisteredAt  time.Time
+	TokenIssuedAt time.Time
+	Reminders     int  // number of reminders already sent
+	Unreminded    bool // the user asked not to be reminded about the verification
 }
 
 type guildUsosInfo struct {
@@ -20,14 +26,81 @@ type guildUsosInfo struct {
 	Filters             []*usos.User
 	LogChannelIDs       map[string]bool
 	AuthorizeMessegeIDs map[string]map[string]bool // maps channelID to a set of message IDs
+	ReuseIdentities     bool                       // authorize users with linked identities without usos login
+	IdentityMaxAge      time.Duration              // linked identities older than that are refreshed, 0 disables refreshing
+	VerifiedMembers     map[string]*verification   // maps user id to their verification
+	AccountPolicy       accountPolicy              // what to do when an usos account verifies another discord account
+	RoleRules           []*roleRule
+	CourseProvisioning  courseProvisioning
+	Reverification      reverificationPolicy
+	Lifecycle           lifecyclePolicy
+	NicknameTemplate    string            // template of verified members' nicknames, empty disables nickname synchronisation
+	Grants              map[string]*grant // maps user id to the authorization granted to him manually
+	BlockedUsosIDs      map[string]*block // maps usos id to its block
+	JoinVerification    joinVerificationPolicy
+	UnverifiedPolicy    unverifiedPolicy
+	Reminders           reminderPolicy
+	DMFallbackChannelID string               // channel to open private threads in for members who cannot be sent private messages
+	CommandPrefix       string               // prefix of the guild's commands, empty for the default one
+	CommandAliases      map[string]string    // maps aliases to paths of the commands they stand for
+	CommandPermissions  commands.Permissions // grants commands requiring privilages to roles and users
+}
+
+// initialize makes sure all the guild info's collections are allocated,
+// as settings saved by older versions may lack some of them
+func (guildInfo *guildUsosInfo) initialize() {
+	if guildInfo.Filters == nil {
+		guildInfo.Filters = make([]*usos.User, 0)
+	}
+	if guildInfo.LogChannelIDs == nil {
+		guildInfo.LogChannelIDs = make(map[string]bool)
+	}
+	if guildInfo.AuthorizeMessegeIDs == nil {
+		guildInfo.AuthorizeMessegeIDs = make(map[string]map[string]bool)
+	}
+	if guildInfo.RoleRules == nil {
+		guildInfo.RoleRules = make([]*roleRule, 0)
+	}
+	if guildInfo.VerifiedMembers == nil {
+		guildInfo.VerifiedMembers = make(map[string]*verification)
+	}
+	if guildInfo.Grants == nil {
+		guildInfo.Grants = make(map[string]*grant)
+	}
+	if guildInfo.BlockedUsosIDs == nil {
+		guildInfo.BlockedUsosIDs = make(map[string]*block)
+	}
+	if guildInfo.CommandAliases == nil {
+		guildInfo.CommandAliases = make(map[string]string)
+	}
+	if guildInfo.CommandPermissions == nil {
+		guildInfo.CommandPermissions = make(commands.Permissions)
+	}
+	guildInfo.CourseProvisioning.initialize()
+	guildInfo.UnverifiedPolicy.initialize()
 }
 
 // UsosBot represents a session of usos authorization bot
 type UsosBot struct {
 	*discordgo.Session
 
-	tokenMap       map[string]*requestTokenGuildPair // maps user id to their auth token
-	guildUsosInfos map[string]*guildUsosInfo         // maps guild id to its info
+	tokenMap       map[string]map[string]*requestTokenGuildPair // maps user id to guild id to their auth token
+	guildUsosInfos map[string]*guildUsosInfo                    // maps guild id to its info
+	identities     map[string]*linkedIdentity                   // maps user id to their linked usos identity
+
+	// mu guards the bot's state between event handlers and scheduled jobs, it is held only while
+	// the state is read or written and never during requests to discord or usos
+	mu            sync.Mutex
+	provisionMu   sync.Mutex // serializes course provisioning, taken before mu and never while holding it
+	schedulerStop chan struct{}
+	joinSlots     map[string]time.Time // maps guild id to the earliest time of its next join authorization
+	setupPlans    map[string]*gatePlan // maps guild id to its setup awaiting confirmation
+
+	parser *commands.DiscordParser // the bot's command tree, built once and shared by all invocations
+
+	// RequestTokenLifetime is the time after which usos request tokens are renewed, defaultRequestTokenLifetime if zero.
+	// It has to be set before the bot is opened.
+	RequestTokenLifetime time.Duration
 }
 
 // New creates a new session of usos authorization bot
@@ -45,14 +118,23 @@ func New(Token string) (*UsosBot, error) {
 	bot := &UsosBot{
 		Session: session,
 
-		tokenMap:       make(map[string]*requestTokenGuildPair),
+		tokenMap:       make(map[string]map[string]*requestTokenGuildPair),
 		guildUsosInfos: make(map[string]*guildUsosInfo),
+		identities:     make(map[string]*linkedIdentity),
+		joinSlots:      make(map[string]time.Time),
+		setupPlans:     make(map[string]*gatePlan),
+	}
+	bot.parser, err = bot.setupCommandParser()
+	if err != nil {
+		return nil, err
 	}
 
 	bot.AddHandler(bot.handlerMessageCreate)
 	bot.AddHandler(bot.handlerReady)
 	bot.AddHandler(bot.handlerReactionAdd)
+	bot.AddHandler(bot.handlerInteractionCreate)
 
+	bot.AddHandler(bot.handlerGuildMemberAdd)
 	bot.AddHandler(bot.handlerGuildMemberRemove)
 	bot.AddHandler(bot.handlerMessageDelete)
 	bot.AddHandler(bot.handlerGuildRoleDelete)
@@ -65,28 +147,30 @@ func New(Token string) (*UsosBot, error) {
 
 // getGuildUsosInfo gives access to guild's usos info
 func (bot *UsosBot) getGuildUsosInfo(guildID string) *guildUsosInfo {
-	if bot.guildUsosInfos[guildID] == nil {
-		bot.guildUsosInfos[guildID] = &guildUsosInfo{
-			LogChannelIDs:       make(map[string]bool),
-			Filters:             make([]*usos.User, 0),
-			AuthorizeMessegeIDs: make(map[string]map[string]bool),
-		}
-		return bot.guildUsosInfos[guildID]
+	guildInfo := bot.guildUsosInfos[guildID]
+	if guildInfo == nil {
+		guildInfo = &guildUsosInfo{}
+		bot.guildUsosInfos[guildID] = guildInfo
 	}
-	return bot.guildUsosInfos[guildID]
+	guildInfo.initialize()
+	return guildInfo
 }
 
 // getLogChannel returns log channel if the given channel id is still valid or nil pointer otherwise
 func (bot *UsosBot) getLogChannel(guildID string, channelID string) (*discordgo.Channel, error) {
-	guildInfo := bot.getGuildUsosInfo(guildID)
-	if !guildInfo.LogChannelIDs[channelID] {
+	bot.mu.Lock()
+	present := bot.getGuildUsosInfo(guildID).LogChannelIDs[channelID]
+	bot.mu.Unlock()
+	if !present {
 		return nil, newErrLogChannelNotFound(channelID, guildID)
 	}
 	channel, err := bot.Channel(channelID)
 	if err != nil {
 		if IsNotFound(err) {
 			// channel was deleted, remove it from log channels autmatically
-			delete(guildInfo.LogChannelIDs, channelID)
+			bot.mu.Lock()
+			delete(bot.getGuildUsosInfo(guildID).LogChannelIDs, channelID)
+			bot.mu.Unlock()
 			return nil, newErrChannelNotFound(err, channelID)
 		}
 		return nil, err
@@ -94,30 +178,51 @@ func (bot *UsosBot) getLogChannel(guildID string, channelID string) (*discordgo.
 	return channel, nil
 }
 
+// logChannelIDs returns ids of the guild's log channels
+func (bot *UsosBot) logChannelIDs(guildID string) []string {
+	channelIDs := make([]string, 0)
+	for channelID := range bot.getGuildUsosInfo(guildID).LogChannelIDs {
+		channelIDs = append(channelIDs, channelID)
+	}
+	return channelIDs
+}
+
 // privMsgDiscord sends a private message to a user with the given text
 func (bot *UsosBot) privMsgDiscord(userID string, text string) error {
+	return bot.privMsgDiscordComplex(userID, &discordgo.MessageSend{Content: text})
+}
+
+// privMsgDiscordComplex sends the private message to a user
+func (bot *UsosBot) privMsgDiscordComplex(userID string, msg *discordgo.MessageSend) error {
 	channel, err := bot.UserChannelCreate(userID)
 	if err != nil {
-		log.Println(err)
+		return newErrPrivateMessageFailed(err, userID)
 	}
-
-	_, err = bot.ChannelMessageSend(channel.ID, text)
+	_, err = bot.ChannelMessageSendComplex(channel.ID, msg)
 	if err != nil {
-		log.Println(err)
+		return newErrPrivateMessageFailed(err, userID)
 	}
 	return nil
 }
 
 type settings struct {
-	TokenMap       map[string]*requestTokenGuildPair `json:"tokenMap"`
-	GuildUsosInfos map[string]*guildUsosInfo         `json:"guildUsosInfos"`
+	TokenMap       map[string]map[string]*requestTokenGuildPair `json:"guildTokenMap"`
+	GuildUsosInfos map[string]*guildUsosInfo                    `json:"guildUsosInfos"`
+	Identities     map[string]*linkedIdentity                   `json:"identities"`
+
+	// LegacyTokenMap holds pending verifications saved before they were bound to guilds, only read
+	LegacyTokenMap map[string]*requestTokenGuildPair `json:"tokenMap,omitempty"`
 }
 
 // ExportSettings exports current bot settings on all servers to a json file
 func (bot *UsosBot) ExportSettings(w io.Writer) error {
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+
 	stngs := settings{
 		TokenMap:       bot.tokenMap,
 		GuildUsosInfos: bot.guildUsosInfos,
+		Identities:     bot.identities,
 	}
 	err := json.NewEncoder(w).Encode(&stngs)
 
@@ -131,6 +236,9 @@ func (bot *UsosBot) ExportSettings(w io.Writer) error {
 // ImportSettings imports bot settings on all servers from a json file
 // (overrides current settings)
 func (bot *UsosBot) ImportSettings(r io.Reader) error {
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+
 	stngs := settings{}
 
 	err := json.NewDecoder(r).Decode(&stngs)
@@ -140,6 +248,23 @@ func (bot *UsosBot) ImportSettings(r io.Reader) error {
 
 	bot.guildUsosInfos = stngs.GuildUsosInfos
 	bot.tokenMap = stngs.TokenMap
+	if bot.tokenMap == nil {
+		bot.tokenMap = make(map[string]map[string]*requestTokenGuildPair)
+	}
+	// settings saved before pending verifications were bound to guilds
+	for userID, pair := range stngs.LegacyTokenMap {
+		if pair == nil || pair.GuildID == "" || bot.tokenMap[userID][pair.GuildID] != nil {
+			continue
+		}
+		if bot.tokenMap[userID] == nil {
+			bot.tokenMap[userID] = make(map[string]*requestTokenGuildPair)
+		}
+		bot.tokenMap[userID][pair.GuildID] = pair
+	}
+	bot.identities = stngs.Identities
+	if bot.identities == nil {
+		bot.identities = make(map[string]*linkedIdentity)
+	}
 
 	return nil
 }
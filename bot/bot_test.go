@@ -4,9 +4,13 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Ogurczak/discord-usos-auth/usos"
+	"github.com/bwmarrin/discordgo"
+	"github.com/dghubble/oauth1"
 )
 
 func TestExportImportSettings(t *testing.T) {
@@ -16,18 +20,33 @@ func TestExportImportSettings(t *testing.T) {
 	}
 
 	bot := &UsosBot{
-		tokenMap: map[string]*requestTokenGuildPair{
+		tokenMap: map[string]map[string]*requestTokenGuildPair{
 			"userID": {
-				GuildID: "guildID",
-				RequestToken: &usos.RequestToken{
-					Token:            "token",
-					Secret:           "secret",
-					AuthorizationURL: url,
+				"guildID": {
+					GuildID: "guildID",
+					RequestToken: &usos.RequestToken{
+						Token:            "token",
+						Secret:           "secret",
+						AuthorizationURL: url,
+					},
+					RegisteredAt:  time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC),
+					TokenIssuedAt: time.Date(2020, 10, 1, 13, 0, 0, 0, time.UTC),
+					Reminders:     1,
 				},
 			},
 		},
+		identities: map[string]*linkedIdentity{
+			"userID": {
+				UsosID:      "ID",
+				User:        &usos.User{ID: "ID", FirstName: "Witold", LastName: "Wysota"},
+				AccessToken: oauth1.NewToken("token", "secret"),
+				FetchedAt:   time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC),
+			},
+		},
 		guildUsosInfos: map[string]*guildUsosInfo{
 			"guilID": {
+				ReuseIdentities: true,
+				IdentityMaxAge:  time.Hour,
 				AuthorizeMessegeIDs: map[string]map[string]bool{
 					"channelID": {
 						"messageID1": true,
@@ -80,4 +99,305 @@ func TestExportImportSettings(t *testing.T) {
 	if !reflect.DeepEqual(bot2.tokenMap, bot.tokenMap) {
 		t.Errorf("TokenMap do not match")
 	}
+
+	// access tokens are not saved
+	if bot2.identities["userID"].AccessToken != nil {
+		t.Errorf("AccessToken was saved")
+	}
+	bot2.identities["userID"].AccessToken = bot.identities["userID"].AccessToken
+	if !reflect.DeepEqual(bot2.identities, bot.identities) {
+		t.Errorf("Identities do not match")
+	}
+}
+
+func TestImportLegacySettings(t *testing.T) {
+	bot := &UsosBot{}
+	err := bot.ImportSettings(strings.NewReader(`{"tokenMap": {"userID": {"GuildID": "guildID"}, "noGuildID": {}},
+		"guildUsosInfos": {}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair := bot.tokenMap["userID"]["guildID"]; pair == nil || pair.GuildID != "guildID" {
+		t.Errorf("pending verification was not migrated: %v", bot.tokenMap)
+	}
+	if len(bot.tokenMap) != 1 {
+		t.Errorf("pending verification without a guild was migrated: %v", bot.tokenMap)
+	}
+}
+
+func TestRemoveUnauthorizedUser(t *testing.T) {
+	bot := &UsosBot{
+		tokenMap: map[string]map[string]*requestTokenGuildPair{
+			"userID": {
+				"guildID1": {GuildID: "guildID1"},
+				"guildID2": {GuildID: "guildID2"},
+			},
+		},
+	}
+
+	err := bot.removeUnauthorizedUser("userID", "guildID1")
+	if err != nil {
+		t.Error(err)
+	}
+	if bot.tokenMap["userID"]["guildID2"] == nil {
+		t.Errorf("Pending verification on another guild removed")
+	}
+
+	err = bot.removeUnauthorizedUser("userID", "guildID1")
+	if _, ok := err.(*ErrUnregisteredUserNotFound); !ok {
+		t.Errorf("want ErrUnregisteredUserNotFound, got %v", err)
+	}
+
+	err = bot.removeUnauthorizedUser("userID", "")
+	if err != nil {
+		t.Error(err)
+	}
+	if _, exists := bot.tokenMap["userID"]; exists {
+		t.Errorf("Pending verifications not removed")
+	}
+}
+
+func TestReminderNotDue(t *testing.T) {
+	bot := &UsosBot{guildUsosInfos: make(map[string]*guildUsosInfo)}
+	bot.getGuildUsosInfo("guildID").Reminders.Enabled = true
+
+	legacy := &requestTokenGuildPair{GuildID: "guildID"}
+	if bot.reminderDue("guildID", legacy) {
+		t.Errorf("reminder due for a legacy registration")
+	}
+	if legacy.RegisteredAt.IsZero() || legacy.Reminders != 0 {
+		t.Errorf("legacy registration not initialized: %+v", legacy)
+	}
+
+	fresh := &requestTokenGuildPair{GuildID: "guildID", RegisteredAt: time.Now(), TokenIssuedAt: time.Now()}
+	if bot.reminderDue("guildID", fresh) || fresh.Reminders != 0 {
+		t.Errorf("reminder due before its delay")
+	}
+
+	unreminded := &requestTokenGuildPair{GuildID: "guildID", RegisteredAt: time.Now().Add(-48 * time.Hour),
+		TokenIssuedAt: time.Now(), Unreminded: true}
+	if bot.reminderDue("guildID", unreminded) {
+		t.Errorf("reminder due after the user stopped them")
+	}
+}
+
+func TestVerifiedAccount(t *testing.T) {
+	bot := &UsosBot{
+		guildUsosInfos: map[string]*guildUsosInfo{
+			"guildID": {
+				VerifiedMembers: map[string]*verification{
+					"userID1": {UsosID: "usosID1"},
+					"userID2": {UsosID: "usosID2"},
+				},
+			},
+		},
+	}
+
+	if got := bot.verifiedAccount("guildID", "userID3", "usosID1"); got != "userID1" {
+		t.Errorf("want userID1, got %q", got)
+	}
+	if got := bot.verifiedAccount("guildID", "userID1", "usosID1"); got != "" {
+		t.Errorf("want no account, got %q", got)
+	}
+	if got := bot.verifiedAccount("otherGuildID", "userID3", "usosID1"); got != "" {
+		t.Errorf("want no account, got %q", got)
+	}
+}
+
+func TestRecordVerificationExpiry(t *testing.T) {
+	now := time.Date(2021, 1, 10, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2021, 2, 21, 0, 0, 0, 0, time.UTC)
+	terms := []*usos.Term{
+		{ID: "2020Z", EndDate: end},
+		{ID: "2020", EndDate: end.AddDate(0, -1, 0)},
+	}
+	tests := []struct {
+		name  string
+		terms []*usos.Term
+		grace time.Duration
+		now   time.Time
+		want  time.Time
+	}{
+		{"term in progress", terms, 0, now, end},
+		{"term ended", terms, 0, end.AddDate(0, 0, 1), end.AddDate(0, 0, 2)},
+		{"term ended with grace", terms, 7 * 24 * time.Hour, end, end.AddDate(0, 0, 7)},
+		{"no terms", nil, 0, now, now.Add(minReverificationDelay)},
+	}
+	for _, test := range tests {
+		got := verificationExpiry(&usos.User{ID: "usosID", Terms: test.terms}, test.grace, test.now)
+		if !got.Equal(test.want) {
+			t.Errorf("%s: want %v, got %v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestSetupCommandParser(t *testing.T) {
+	bot := &UsosBot{}
+	_, err := bot.setupCommandParser()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestApplicationCommands(t *testing.T) {
+	bot := &UsosBot{}
+	parser, err := bot.setupCommandParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	appCommands, err := parser.ApplicationCommands()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var checkOptions func(path string, options []*discordgo.ApplicationCommandOption)
+	checkOptions = func(path string, options []*discordgo.ApplicationCommandOption) {
+		if len(options) > 25 {
+			t.Errorf("%s has %d options, at most 25 allowed", path, len(options))
+		}
+		optional := false
+		for _, option := range options {
+			if len(option.Name) > 32 || strings.ToLower(option.Name) != option.Name {
+				t.Errorf("%s has invalid option name %q", path, option.Name)
+			}
+			if option.Required && optional {
+				t.Errorf("%s has required option %s after an optional one", path, option.Name)
+			}
+			optional = optional || !option.Required
+			checkOptions(path+" "+option.Name, option.Options)
+		}
+	}
+	for _, cmd := range appCommands {
+		checkOptions(cmd.Name, cmd.Options)
+	}
+}
+
+func TestAddCommandAlias(t *testing.T) {
+	bot := &UsosBot{guildUsosInfos: make(map[string]*guildUsosInfo)}
+	parser, err := bot.setupCommandParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot.parser = parser
+
+	err = bot.addCommandAlias("guildID", "fa", "filter  add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bot.getGuildUsosInfo("guildID").CommandAliases["fa"]; got != "filter add" {
+		t.Errorf("alias stands for %q, want %q", got, "filter add")
+	}
+	for alias, path := range map[string]string{"fa": "filter", "filter": "role", "-f": "filter", "x": "nope"} {
+		if err := bot.addCommandAlias("guildID", alias, path); err == nil {
+			t.Errorf("alias %s of %s added", alias, path)
+		}
+	}
+}
+
+func TestInteractionArgs(t *testing.T) {
+	bot := &UsosBot{}
+	parser, err := bot.setupCommandParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := discordgo.ApplicationCommandInteractionData{
+		Name: "courses",
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+			Name: "approve",
+			Type: discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{
+				{Name: "course", Type: discordgo.ApplicationCommandOptionString, Value: `"1000-ANA 1" 1000-ALG`},
+			},
+		}},
+	}
+	want := []string{"courses", "approve", "--course=1000-ANA 1", "--course=1000-ALG"}
+	got, err := parser.InteractionArgs(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("InteractionArgs() = %v, want %v", got, want)
+	}
+
+	data.Options[0].Options[0].Value = `"unterminated`
+	if _, err := parser.InteractionArgs(data); err == nil {
+		t.Error("InteractionArgs() accepted an unterminated quote")
+	}
+
+	// arguments of commands with subcommands are taken by a slash subcommand
+	data = discordgo.ApplicationCommandInteractionData{
+		Name: "grant",
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+			Name: "add",
+			Type: discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{
+				{Name: "user", Type: discordgo.ApplicationCommandOptionString, Value: "<@userID>"},
+				{Name: "reason", Type: discordgo.ApplicationCommandOptionString, Value: "-h"},
+			},
+		}},
+	}
+	want = []string{"grant", "--reason=-h", "--", "<@userID>"}
+	got, err = parser.InteractionArgs(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("InteractionArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestVerifierPattern(t *testing.T) {
+	for _, verifier := range []string{"12345678", "0000"} {
+		if !verifierPattern.MatchString(verifier) {
+			t.Errorf("%q should look like a verifier", verifier)
+		}
+	}
+	for _, msg := range []string{"", "hello", "1234 5678", "!usos verify -c 12345678", "123"} {
+		if verifierPattern.MatchString(msg) {
+			t.Errorf("%q should not look like a verifier", msg)
+		}
+	}
+}
+
+func TestInvocationInPrivateMessages(t *testing.T) {
+	state := discordgo.NewState()
+	state.User = &discordgo.User{ID: "botID"}
+	bot := &UsosBot{
+		Session: &discordgo.Session{State: state},
+		guildUsosInfos: map[string]*guildUsosInfo{
+			"guildID":       {CommandPrefix: "?"},
+			"legacyGuildID": {CommandPrefix: "1"},
+		},
+	}
+
+	for content, want := range map[string]bool{
+		"!usos help":    true,
+		"? help":        true,
+		"1 help":        false,
+		"<@botID>":      false,
+		"<@!botID> ":    false,
+		"<@botID> help": true,
+	} {
+		inv := bot.invocation(&discordgo.MessageCreate{Message: &discordgo.Message{Content: content}})
+		if got := inv != nil; got != want {
+			t.Errorf("invocation(%q) != nil is %v, want %v", content, got, want)
+		}
+	}
+	if validateCommandPrefix("1") == nil {
+		t.Error("prefix starting with a digit accepted")
+	}
+}
+
+func TestUserStatusEmpty(t *testing.T) {
+	bot := &UsosBot{
+		tokenMap:       make(map[string]map[string]*requestTokenGuildPair),
+		guildUsosInfos: map[string]*guildUsosInfo{"guildID": {}},
+	}
+	status, err := bot.userStatus("userID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != "You are neither verified nor pending verification on any server" {
+		t.Errorf("unexpected status %q", status)
+	}
 }
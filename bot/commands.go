@@ -4,29 +4,32 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/Ogurczak/discord-usos-auth/bot/commands"
 	"github.com/Ogurczak/discord-usos-auth/usos"
 	"github.com/Ogurczak/discord-usos-auth/utils"
-	"github.com/akamensky/argparse"
 	"github.com/bwmarrin/discordgo"
 )
 
 func (bot *UsosBot) setupCommandParser() (*commands.DiscordParser, error) {
-	parser := commands.NewDiscordParser("!usos", "Execute the Usos Authrization Bot's discord commands", bot.Session)
+	parser := commands.NewDiscordParser(defaultCommandPrefix, "Execute the Usos Authrization Bot's discord commands", bot.Session)
 
-	authMsgCmd := parser.NewCommand("auth-msg", "Spawn a message which upon being reacted to begins the process of usos authentication")
+	authMsgCmd := parser.NewCommand("auth-msg", "Spawn a message with a button which begins the process of usos authentication")
 	authMsgCmd.PrivilagesRequired = true
 	err := authMsgCmd.SetScope(commands.ScopeGuild)
 	if err != nil {
 		return nil, err
 	}
-	prompt := authMsgCmd.String("m", "msg",
-		&argparse.Options{Required: false,
+	authMsgCmd.String("m", "msg",
+		&commands.Options{Required: false,
 			Help:    "custom prompt on the message",
-			Default: "React to this message to get authorized!"})
-	authMsgCmd.Handler = func(cmd *commands.DiscordCommand, e *discordgo.MessageCreate) *commands.ErrHandler {
-		err := bot.spawnAuthorizeMessage(e.GuildID, e.ChannelID, *prompt)
+			Default: "Click the button below to get authorized!"})
+	authMsgCmd.AddExample(`-m "Click the button below to get access to the server"`)
+	authMsgCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		err := bot.spawnAuthorizeMessage(e.GuildID, e.ChannelID, ctx.String("msg"))
 		if err != nil {
 			return commands.NewErrHandler(err, true)
 		}
@@ -38,15 +41,25 @@ func (bot *UsosBot) setupCommandParser() (*commands.DiscordParser, error) {
 	if err != nil {
 		return nil, err
 	}
-	verifier := verifyCmd.String("c", "code",
-		&argparse.Options{Required: false,
+	verifyCmd.String("c", "code",
+		&commands.Options{Required: false,
 			Help: "verification code"})
-	abort := verifyCmd.Flag("a", "abort",
-		&argparse.Options{Required: false,
+	verifyCmd.Flag("a", "abort",
+		&commands.Options{Required: false,
 			Help: "abort current verification process"})
-	verifyCmd.Handler = func(cmd *commands.DiscordCommand, e *discordgo.MessageCreate) *commands.ErrHandler {
-		if *abort {
-			err := bot.removeUnauthorizedUser(e.Author.ID)
+	verifyCmd.Flag("q", "quiet",
+		&commands.Options{Required: false,
+			Help: "stop reminders about your pending verifications without aborting them"})
+	verifyCmd.Flag("r", "remember",
+		&commands.Options{Required: false,
+			Help: "remember your usos identity to skip verification on other servers which allow it"})
+	verifyCmd.AddExample(`-c 12345678`)
+	verifyCmd.AddExample(`-a`)
+	verifyCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		if ctx.Flag("abort") {
+			bot.mu.Lock()
+			err := bot.removeUnauthorizedUser(e.Author.ID, "")
+			bot.mu.Unlock()
 			switch err.(type) {
 			case *ErrUnregisteredUserNotFound:
 				return commands.NewErrHandler(err, true)
@@ -61,23 +74,1226 @@ func (bot *UsosBot) setupCommandParser() (*commands.DiscordParser, error) {
 			}
 
 		}
-		if *verifier == "" {
-			// ugly but i don't see a better approach with this argparse library
-			return commands.NewErrHandler(errors.New("[-c|--code] or [-a|--abort] is required"), true)
+		if ctx.Flag("quiet") {
+			bot.mu.Lock()
+			err := bot.stopReminders(e.Author.ID)
+			bot.mu.Unlock()
+			if err != nil {
+				return commands.NewErrHandler(err, true)
+			}
+			_, err = bot.ChannelMessageSend(e.ChannelID, "You will not be reminded about your pending verifications")
+			if err != nil {
+				return commands.NewErrHandler(err, false)
+			}
+			return nil
+		}
+		if ctx.String("code") == "" {
+			return commands.NewErrHandler(errors.New("[-c|--code], [-a|--abort] or [-q|--quiet] is required"), true)
+		}
+		if ctx.Flag("remember") {
+			bot.mu.Lock()
+			bot.consentIdentity(e.Author.ID)
+			bot.mu.Unlock()
+		}
+		results, err := bot.finalizeAuthorization(e.Author, ctx.String("code"))
+		switch err.(type) {
+		case *ErrUnregisteredUnauthorizedUser, *usos.ErrUnableToCall, *ErrWrongVerifier:
+			return commands.NewErrHandler(err, true)
+		case nil:
+			err = bot.privMsgDiscord(e.Author.ID, bot.authorizationSummary(results))
+			if err != nil {
+				return commands.NewErrHandler(err, false)
+			}
+			return nil
+		default:
+			return commands.NewErrHandler(err, false)
+		}
+	}
+
+	identityCmd := parser.NewCommand("identity", "manage your usos identity linked across servers")
+	err = identityCmd.SetScope(commands.ScopePrivate)
+	if err != nil {
+		return nil, err
+	}
+
+	consentIdentityCmd := identityCmd.NewCommand("consent", "Consent to remembering your usos identity upon your next verification")
+	consentIdentityCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		bot.consentIdentity(e.Author.ID)
+		bot.mu.Unlock()
+		_, err := bot.ChannelMessageSend(e.ChannelID,
+			"Your usos identity will be remembered upon your next verification and reused on servers which allow it")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	forgetIdentityCmd := identityCmd.NewCommand("forget", "Withdraw the consent and forget your linked usos identity")
+	forgetIdentityCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		err := bot.forgetIdentity(e.Author.ID)
+		bot.mu.Unlock()
+		if err != nil {
+			return commands.NewErrHandler(err, true)
+		}
+		_, err = bot.ChannelMessageSend(e.ChannelID, "Your usos identity was forgotten")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	showIdentityCmd := identityCmd.NewCommand("show", "Show your linked usos identity")
+	showIdentityCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		var identity linkedIdentity
+		linked := bot.identities[e.Author.ID] != nil
+		if linked {
+			identity = *bot.identities[e.Author.ID]
+		}
+		bot.mu.Unlock()
+		if !linked {
+			return commands.NewErrHandler(newErrIdentityNotLinked(e.Author.ID), true)
+		}
+		if identity.User == nil {
+			_, err := bot.ChannelMessageSend(e.ChannelID,
+				"You consented to linking your usos identity, it will be linked upon your next verification")
+			if err != nil {
+				return commands.NewErrHandler(err, false)
+			}
+			return nil
+		}
+		body, err := json.MarshalIndent(identity.User, "", "  ")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		msg := fmt.Sprintf("Your linked usos identity (fetched %s):", identity.FetchedAt.Format(time.RFC1123))
+		_, err = bot.ChannelMessageSend(e.ChannelID, msg+utils.DiscordCodeBlock(string(body), "json"))
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	statusCmd := parser.NewCommand("status", "Show your pending and finished verifications on all servers")
+	statusCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		status, err := bot.userStatus(e.Author.ID)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		if e.GuildID == "" {
+			err = bot.channelMessageSendLong(e.ChannelID, status)
+			if err != nil {
+				return commands.NewErrHandler(err, false)
+			}
+			return nil
+		}
+
+		// do not reveal the status in server chats
+		if ctx.Interaction != nil {
+			ctx.Response = status
+			return nil
+		}
+		member, err := bot.guildMember(e.GuildID, e.Author.ID)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		err = bot.msgMemberText(member, status)
+		if err != nil {
+			_, userFacing := err.(*ErrPrivateMessageFailed)
+			return commands.NewErrHandler(err, userFacing)
+		}
+		_, err = bot.ChannelMessageSend(e.ChannelID, "Your status was sent to you privately")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	reuseCmd := parser.NewCommand("reuse", "manage authorizing users with their usos identities linked on other servers")
+	reuseCmd.PrivilagesRequired = true
+	err = reuseCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+	reuseCmd.Flag("e", "enable", &commands.Options{Required: false,
+		Help: "authorize users with linked identities without usos login"})
+	reuseCmd.Flag("d", "disable", &commands.Options{Required: false,
+		Help: "always require usos login"})
+	reuseCmd.Int("a", "max-age", &commands.Options{Required: false,
+		Help:    "refresh linked identities older than the given number of hours, 0 disables refreshing",
+		Default: -1})
+	reuseCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		if ctx.Flag("enable") && ctx.Flag("disable") {
+			return commands.NewErrHandler(errors.New("[-e|--enable] and [-d|--disable] are mutually exclusive"), true)
+		}
+		bot.mu.Lock()
+		guildInfo := bot.getGuildUsosInfo(e.GuildID)
+		if ctx.Flag("enable") {
+			guildInfo.ReuseIdentities = true
+		}
+		if ctx.Flag("disable") {
+			guildInfo.ReuseIdentities = false
+		}
+		if ctx.Int("max-age") >= 0 {
+			guildInfo.IdentityMaxAge = time.Duration(ctx.Int("max-age")) * time.Hour
+		}
+
+		msg := "Linked identities are not reused on this server"
+		if guildInfo.ReuseIdentities {
+			msg = "Linked identities are reused on this server"
+			if guildInfo.IdentityMaxAge > 0 {
+				msg += fmt.Sprintf(", refreshed if older than %s", guildInfo.IdentityMaxAge)
+			}
+		}
+		bot.mu.Unlock()
+		_, err := bot.ChannelMessageSend(e.ChannelID, msg)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	accountPolicyCmd := parser.NewCommand("account-policy",
+		"set what happens when an usos account verifies another discord account on this server")
+	accountPolicyCmd.PrivilagesRequired = true
+	err = accountPolicyCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+	accountPolicyCmd.Selector("p", "policy", accountPolicies, &commands.Options{Required: false,
+		Help: "allow - let it verify, deny - refuse verification, move - verify it and deauthorize the old account"})
+	accountPolicyCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		guildInfo := bot.getGuildUsosInfo(e.GuildID)
+		if ctx.String("policy") != "" {
+			guildInfo.AccountPolicy = accountPolicy(ctx.String("policy"))
+		}
+		current := guildInfo.AccountPolicy
+		bot.mu.Unlock()
+		if current == "" {
+			current = accountPolicyAllow
+		}
+		_, err := bot.ChannelMessageSend(e.ChannelID, "Account policy: "+utils.DiscordCodeSpan(current))
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	reverificationCmd := parser.NewCommand("reverification", "manage re-verification of members at the end of their terms")
+	reverificationCmd.PrivilagesRequired = true
+	err = reverificationCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+	reverificationCmd.Flag("e", "enable", &commands.Options{Required: false,
+		Help: "re-verify members when their terms end"})
+	reverificationCmd.Flag("d", "disable", &commands.Options{Required: false,
+		Help: "keep members verified forever"})
+	reverificationCmd.Int("g", "grace", &commands.Options{Required: false,
+		Help:    "number of days members not qualifying anymore keep their roles",
+		Default: -1})
+	reverificationCmd.Flag("n", "notify", &commands.Options{Required: false,
+		Help: "notify members about their expiring verification"})
+	reverificationCmd.Flag("q", "quiet", &commands.Options{Required: false,
+		Help: "do not notify members about their expiring verification"})
+	reverificationCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		if ctx.Flag("enable") && ctx.Flag("disable") {
+			return commands.NewErrHandler(errors.New("[-e|--enable] and [-d|--disable] are mutually exclusive"), true)
+		}
+		if ctx.Flag("notify") && ctx.Flag("quiet") {
+			return commands.NewErrHandler(errors.New("[-n|--notify] and [-q|--quiet] are mutually exclusive"), true)
+		}
+		bot.mu.Lock()
+		policy := &bot.getGuildUsosInfo(e.GuildID).Reverification
+		if ctx.Flag("enable") {
+			policy.Enabled = true
+		}
+		if ctx.Flag("disable") {
+			policy.Enabled = false
+		}
+		if ctx.Int("grace") >= 0 {
+			policy.GraceDays = ctx.Int("grace")
+		}
+		if ctx.Flag("notify") {
+			policy.Notify = true
+		}
+		if ctx.Flag("quiet") {
+			policy.Notify = false
+		}
+
+		msg := "Re-verification is disabled"
+		if policy.Enabled {
+			msg = fmt.Sprintf("Re-verification is enabled, grace period: %d days, notifications: %t",
+				policy.GraceDays, policy.Notify)
+		}
+		bot.mu.Unlock()
+		_, err := bot.ChannelMessageSend(e.ChannelID, msg)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	lifecycleCmd := parser.NewCommand("lifecycle", "manage transitioning re-checked members from students to alumni")
+	lifecycleCmd.PrivilagesRequired = true
+	err = lifecycleCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+	lifecycleCmd.Flag("e", "enable", &commands.Options{Required: false,
+		Help: "give graduated members the alumni role instead of their student roles"})
+	lifecycleCmd.Flag("d", "disable", &commands.Options{Required: false,
+		Help: "treat graduated members like any other"})
+	lifecycleCmd.Role("r", "role", &commands.Options{Required: false,
+		Help: "the alumni role"})
+	lifecycleCmd.Int("a", "remove-after", &commands.Options{Required: false,
+		Help:    "number of days after which alumni lose the alumni role, 0 keeps it forever",
+		Default: -1})
+	lifecycleCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		if ctx.Flag("enable") && ctx.Flag("disable") {
+			return commands.NewErrHandler(errors.New("[-e|--enable] and [-d|--disable] are mutually exclusive"), true)
+		}
+		if ctx.String("role") != "" {
+			_, err := bot.guildRole(e.GuildID, ctx.String("role"))
+			if err != nil {
+				if IsNotFound(err) {
+					return commands.NewErrHandler(err, true)
+				}
+				return commands.NewErrHandler(err, false)
+			}
+		}
+
+		bot.mu.Lock()
+		lifecycle := &bot.getGuildUsosInfo(e.GuildID).Lifecycle
+		if ctx.String("role") != "" {
+			lifecycle.AlumniRoleID = ctx.String("role")
+		}
+		if ctx.Flag("enable") {
+			if lifecycle.AlumniRoleID == "" {
+				bot.mu.Unlock()
+				return commands.NewErrHandler(errors.New("[-r|--role] is required to enable the lifecycle"), true)
+			}
+			lifecycle.Enabled = true
+		}
+		if ctx.Flag("disable") {
+			lifecycle.Enabled = false
+		}
+		if ctx.Int("remove-after") >= 0 {
+			lifecycle.RemoveAfterDays = ctx.Int("remove-after")
+		}
+
+		msg := "Lifecycle is disabled"
+		if lifecycle.Enabled {
+			msg = fmt.Sprintf("Lifecycle is enabled: active student → alumnus (<@&%s>)", lifecycle.AlumniRoleID)
+			if lifecycle.RemoveAfterDays > 0 {
+				msg += fmt.Sprintf(" → removed after %d days", lifecycle.RemoveAfterDays)
+			}
+		}
+		bot.mu.Unlock()
+		_, err := bot.ChannelMessageSend(e.ChannelID, msg)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	nicknameCmd := parser.NewCommand("nickname", "manage nicknames of verified members set from their usos data")
+	nicknameCmd.PrivilagesRequired = true
+	err = nicknameCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+
+	setNicknameCmd := nicknameCmd.NewCommand("set", "set the nickname template applied upon authorization")
+	setNicknameCmd.String("t", "template", &commands.Options{Required: true,
+		Help: "nickname template; placeholders: {first_name}, {last_name}, " +
+			"{first_name_initial}, {last_name_initial}, {programme}, {usos_id}"})
+	setNicknameCmd.AddExample(`-t "{first_name} {last_name_initial}. ({programme})"`)
+	setNicknameCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		template := ctx.String("template")
+		bot.mu.Lock()
+		bot.getGuildUsosInfo(e.GuildID).NicknameTemplate = template
+		applyUsage := bot.commandSpan(e.GuildID, "nickname apply")
+		bot.mu.Unlock()
+
+		example := renderNickname(template, &usos.User{ID: "123456", FirstName: "Jan", LastName: "Kowalski",
+			Programmes: []*usos.Programme{{Name: "103C-ISP-IN"}}})
+		_, err := bot.ChannelMessageSend(e.ChannelID, fmt.Sprintf("Nickname template set, e.g. %s. Use %s to apply it to verified members.",
+			utils.DiscordCodeSpan(example), applyUsage))
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	clearNicknameCmd := nicknameCmd.NewCommand("clear", "stop setting nicknames of verified members")
+	clearNicknameCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		bot.getGuildUsosInfo(e.GuildID).NicknameTemplate = ""
+		bot.mu.Unlock()
+		_, err := bot.ChannelMessageSend(e.ChannelID, "Nickname template cleared")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	applyNicknameCmd := nicknameCmd.NewCommand("apply", "apply the nickname template to all verified members who linked their usos identities")
+	applyNicknameCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		template := bot.getGuildUsosInfo(e.GuildID).NicknameTemplate
+		bot.mu.Unlock()
+		if template == "" {
+			return commands.NewErrHandler(errors.New("No nickname template set"), true)
+		}
+		skipped, err := bot.applyNicknames(e.GuildID)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		msg := "Nicknames applied successfully"
+		if skipped > 0 {
+			msg += fmt.Sprintf(", %d members are above the bot in the role hierarchy and were skipped", skipped)
+		}
+		_, err = bot.ChannelMessageSend(e.ChannelID, msg)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	deauthCmd := parser.NewCommand("deauth", "take the authorization away from a member")
+	deauthCmd.PrivilagesRequired = true
+	err = deauthCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+	deauthCmd.Words("user", &commands.Options{Required: true,
+		Help: "mention, ID or name of the member"})
+	deauthCmd.AddExample(`@someone`)
+	deauthCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		userID, err := ctx.User("user")
+		if err != nil {
+			_, isREST := err.(*discordgo.RESTError)
+			return commands.NewErrHandler(err, !isREST)
+		}
+		member, err := bot.guildMember(e.GuildID, userID)
+		if err != nil {
+			return commands.NewErrHandler(err, IsNotFound(err))
+		}
+		err = bot.deauthorizeMember(e.GuildID, member.User.ID)
+		if err != nil && !IsNotFound(err) {
+			return commands.NewErrHandler(err, false)
+		}
+		bot.mu.Lock()
+		bot.removeUnauthorizedUser(member.User.ID, e.GuildID)
+		bot.mu.Unlock()
+
+		err = bot.logDiscord(e.GuildID, fmt.Sprintf("<@%s> deauthorized <@%s>", e.Author.ID, member.User.ID))
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		_, err = bot.ChannelMessageSend(e.ChannelID, "Member deauthorized successfully")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	pendingCmd := parser.NewCommand("pending", "manage pending verifications")
+	pendingCmd.PrivilagesRequired = true
+	err = pendingCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelPendingCmd := pendingCmd.NewCommand("cancel", "forget a user's pending verification on this server")
+	cancelPendingCmd.Words("user", &commands.Options{Required: true,
+		Help: "mention, ID or name of the user"})
+	cancelPendingCmd.AddExample(`@someone`)
+	cancelPendingCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		userID, err := ctx.User("user")
+		if err != nil {
+			_, isREST := err.(*discordgo.RESTError)
+			return commands.NewErrHandler(err, !isREST)
+		}
+		bot.mu.Lock()
+		err = bot.removeUnauthorizedUser(userID, e.GuildID)
+		bot.mu.Unlock()
+		if err != nil {
+			return commands.NewErrHandler(errors.New("This user has no pending verification on this server"), true)
+		}
+
+		err = bot.logDiscord(e.GuildID, fmt.Sprintf("<@%s> cancelled <@%s>'s pending verification", e.Author.ID, userID))
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		_, err = bot.ChannelMessageSend(e.ChannelID, "Pending verification cancelled successfully")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	listPendingCmd := pendingCmd.NewCommand("list", "list users with pending verifications on this server")
+	listPendingCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		msg := ""
+		bot.mu.Lock()
+		for userID, pairs := range bot.tokenMap {
+			if pairs[e.GuildID] != nil {
+				msg += fmt.Sprintf("\n<@%s>", userID)
+			}
+		}
+		bot.mu.Unlock()
+		if msg == "" {
+			msg = "No pending verifications on this server."
+		} else {
+			msg = "Pending verifications:" + msg
+		}
+		err := bot.channelMessageSendLong(e.ChannelID, msg)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	reverifyCmd := parser.NewCommand("reverify", "take the authorization away and ask to verify again")
+	reverifyCmd.PrivilagesRequired = true
+	err = reverifyCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+	reverifyCmd.Words("user", &commands.Options{Required: false,
+		Help: "mention, ID or name of the member"})
+	reverifyCmd.Flag("", "all", &commands.Options{Required: false,
+		Help: "ask all verified members to verify again"})
+	reverifyCmd.AddExample(`@someone`)
+	reverifyCmd.AddExample(`--all`)
+	reverifyCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		userID, err := ctx.User("user")
+		if err != nil {
+			_, isREST := err.(*discordgo.RESTError)
+			return commands.NewErrHandler(err, !isREST)
+		}
+		var userIDs []string
+		switch {
+		case ctx.Flag("all") && userID != "":
+			return commands.NewErrHandler(errors.New("<user> and [--all] are mutually exclusive"), true)
+		case ctx.Flag("all"):
+			bot.mu.Lock()
+			for userID := range bot.getGuildUsosInfo(e.GuildID).VerifiedMembers {
+				userIDs = append(userIDs, userID)
+			}
+			bot.mu.Unlock()
+		case userID != "":
+			userIDs = []string{userID}
+		default:
+			return commands.NewErrHandler(errors.New("<user> or [--all] is required"), true)
+		}
+
+		description := "Server administrators asked you to verify again."
+		reverified := 0
+		for _, userID := range userIDs {
+			member, err := bot.guildMember(e.GuildID, userID)
+			if err != nil {
+				if IsNotFound(err) && ctx.Flag("all") {
+					bot.mu.Lock()
+					delete(bot.getGuildUsosInfo(e.GuildID).VerifiedMembers, userID)
+					bot.mu.Unlock()
+					continue
+				}
+				return commands.NewErrHandler(err, IsNotFound(err))
+			}
+			err = bot.requestReverification(member, description)
+			if err != nil {
+				return commands.NewErrHandler(err, false)
+			}
+			err = bot.logDiscord(e.GuildID, fmt.Sprintf("<@%s> asked <@%s> to verify again", e.Author.ID, userID))
+			if err != nil {
+				return commands.NewErrHandler(err, false)
+			}
+			reverified++
+		}
+
+		_, err = bot.ChannelMessageSend(e.ChannelID, fmt.Sprintf("%d members were asked to verify again", reverified))
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	grantCmd := parser.NewCommand("grant", "grant authorization to a member without usos verification")
+	grantCmd.PrivilagesRequired = true
+	err = grantCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+	grantCmd.SlashName = "add"
+	grantCmd.Words("user", &commands.Options{Required: true,
+		Help: "mention, ID or name of the member, members named like a subcommand need a mention or ID"})
+	grantCmd.String("r", "reason", &commands.Options{Required: false,
+		Help: "reason of the grant"})
+	grantCmd.AddExample(`@mentor -r "guest lecturer"`)
+	grantCmd.AddExample(`list`)
+	grantCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		userID, err := ctx.User("user")
+		if err != nil {
+			_, isREST := err.(*discordgo.RESTError)
+			return commands.NewErrHandler(err, !isREST)
+		}
+		member, err := bot.guildMember(e.GuildID, userID)
+		if err != nil {
+			return commands.NewErrHandler(err, IsNotFound(err))
+		}
+		err = bot.grantAuthorization(member, e.Author.ID, ctx.String("reason"))
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		_, err = bot.ChannelMessageSend(e.ChannelID, "Authorization granted successfully")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	revokeGrantCmd := grantCmd.NewCommand("revoke", "revoke authorization granted manually")
+	revokeGrantCmd.Words("user", &commands.Options{Required: true,
+		Help: "mention, ID or name of the member"})
+	revokeGrantCmd.AddExample(`@mentor`)
+	revokeGrantCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		userID, err := ctx.User("user")
+		if err != nil {
+			_, isREST := err.(*discordgo.RESTError)
+			return commands.NewErrHandler(err, !isREST)
+		}
+		err = bot.revokeGrant(e.GuildID, userID, e.Author.ID)
+		if err != nil {
+			_, userFacing := err.(*ErrGrantNotFound)
+			return commands.NewErrHandler(err, userFacing)
+		}
+		_, err = bot.ChannelMessageSend(e.ChannelID, "Grant revoked successfully")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	listGrantCmd := grantCmd.NewCommand("list", "list authorizations granted manually")
+	listGrantCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		grants := bot.getGuildUsosInfo(e.GuildID).Grants
+		msg := "Granted authorizations:"
+		for userID, grant := range grants {
+			msg += fmt.Sprintf("\n<@%s> by <@%s> on %s", userID, grant.GrantedBy, grant.GrantedAt.Format("2006-01-02"))
+			if grant.Reason != "" {
+				msg += ": " + grant.Reason
+			}
+		}
+		if len(grants) == 0 {
+			msg = "No authorizations granted manually on this server."
+		}
+		bot.mu.Unlock()
+		err := bot.channelMessageSendLong(e.ChannelID, msg)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	blockCmd := parser.NewCommand("block", "manage usos accounts blocked from verifying on this server")
+	blockCmd.PrivilagesRequired = true
+	err = blockCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+
+	addBlockCmd := blockCmd.NewCommand("add", "block an usos account, members verified with it are deauthorized")
+	addBlockCmd.String("i", "id", &commands.Options{Required: false,
+		Help: "usos ID of the account"})
+	addBlockCmd.User("u", "user", &commands.Options{Required: false,
+		Help: "mention, ID or name of a member verified with the account"})
+	addBlockCmd.String("r", "reason", &commands.Options{Required: false,
+		Help: "reason of the block"})
+	addBlockCmd.AddExample(`-u @someone -r "ban evasion"`)
+	addBlockCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		usosID := ctx.String("id")
+		switch {
+		case usosID != "" && ctx.String("user") != "":
+			return commands.NewErrHandler(errors.New("[-i|--id] and [-u|--user] are mutually exclusive"), true)
+		case ctx.String("user") != "":
+			bot.mu.Lock()
+			if verification := bot.getGuildUsosInfo(e.GuildID).VerifiedMembers[ctx.String("user")]; verification != nil {
+				usosID = verification.UsosID
+			}
+			bot.mu.Unlock()
+			if usosID == "" {
+				return commands.NewErrHandler(errors.New("This user is not verified with usos on this server"), true)
+			}
+		case usosID == "":
+			return commands.NewErrHandler(errors.New("[-i|--id] or [-u|--user] is required"), true)
+		}
+
+		err := bot.blockUsosID(e.GuildID, usosID, e.Author.ID, ctx.String("reason"))
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		_, err = bot.ChannelMessageSend(e.ChannelID, "Usos account blocked successfully")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	removeBlockCmd := blockCmd.NewCommand("remove", "unblock an usos account")
+	removeBlockCmd.String("i", "id", &commands.Options{Required: true,
+		Help: "usos ID of the account"})
+	removeBlockCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		err := bot.unblockUsosID(e.GuildID, ctx.String("id"), e.Author.ID)
+		if err != nil {
+			_, userFacing := err.(*ErrBlockNotFound)
+			return commands.NewErrHandler(err, userFacing)
+		}
+		_, err = bot.ChannelMessageSend(e.ChannelID, "Usos account unblocked successfully")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	listBlockCmd := blockCmd.NewCommand("list", "list blocked usos accounts")
+	listBlockCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		blocks := bot.getGuildUsosInfo(e.GuildID).BlockedUsosIDs
+		msg := "Blocked usos accounts:"
+		for usosID, block := range blocks {
+			msg += fmt.Sprintf("\n%s by <@%s> on %s", utils.DiscordCodeSpan(usosID), block.BlockedBy,
+				block.BlockedAt.Format("2006-01-02"))
+			if block.Reason != "" {
+				msg += ": " + block.Reason
+			}
+		}
+		if len(blocks) == 0 {
+			msg = "No usos accounts blocked on this server."
+		}
+		bot.mu.Unlock()
+		err := bot.channelMessageSendLong(e.ChannelID, msg)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	joinCmd := parser.NewCommand("join", "manage starting authorization when members join this server")
+	joinCmd.PrivilagesRequired = true
+	err = joinCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+	joinCmd.Flag("e", "enable", &commands.Options{Required: false,
+		Help: "send authorization instructions to joining members"})
+	joinCmd.Flag("d", "disable", &commands.Options{Required: false,
+		Help: "require members to react to the authorization message"})
+	joinCmd.Int("s", "delay", &commands.Options{Required: false,
+		Help:    "number of seconds between joining and receiving the instructions",
+		Default: -1})
+	joinCmd.String("w", "welcome", &commands.Options{Required: false,
+		Help: "welcome text preceding the instructions"})
+	joinCmd.AddExample(`-e -s 30 -w "Welcome to the server!"`)
+	joinCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		if ctx.Flag("enable") && ctx.Flag("disable") {
+			return commands.NewErrHandler(errors.New("[-e|--enable] and [-d|--disable] are mutually exclusive"), true)
+		}
+		bot.mu.Lock()
+		policy := &bot.getGuildUsosInfo(e.GuildID).JoinVerification
+		if ctx.Flag("enable") {
+			policy.Enabled = true
+		}
+		if ctx.Flag("disable") {
+			policy.Enabled = false
+		}
+		if ctx.Int("delay") >= 0 {
+			policy.DelaySeconds = ctx.Int("delay")
+		}
+		if ctx.String("welcome") != "" {
+			policy.WelcomeText = ctx.String("welcome")
+		}
+
+		msg := "Authorization is not started when members join"
+		if policy.Enabled {
+			msg = fmt.Sprintf("Authorization is started %d seconds after members join", policy.DelaySeconds)
+			if policy.WelcomeText != "" {
+				msg += ", welcome text: " + policy.WelcomeText
+			}
+		}
+		bot.mu.Unlock()
+		_, err := bot.ChannelMessageSend(e.ChannelID, msg)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	unverifiedCmd := parser.NewCommand("unverified", "manage reminding and kicking members who stay unverified")
+	unverifiedCmd.PrivilagesRequired = true
+	err = unverifiedCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+	unverifiedCmd.Flag("e", "enable", &commands.Options{Required: false,
+		Help: "remind and kick members who stay unverified"})
+	unverifiedCmd.Flag("d", "disable", &commands.Options{Required: false,
+		Help: "let members stay unverified"})
+	unverifiedCmd.Int("r", "remind-after", &commands.Options{Required: false,
+		Help:    "number of days after joining unverified members are reminded, 0 disables reminders",
+		Default: -1})
+	unverifiedCmd.Int("k", "kick-after", &commands.Options{Required: false,
+		Help:    "number of days after joining unverified members are kicked, 0 disables kicking",
+		Default: -1})
+	unverifiedCmd.StringList("x", "exempt", &commands.Options{Required: false,
+		Help: "roles whose members are exempt given by mentions, IDs or names, replace the current ones"})
+	unverifiedCmd.AddExample(`-e -r 3 -k 7 -x @Guest`)
+	unverifiedCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		if ctx.Flag("enable") && ctx.Flag("disable") {
+			return commands.NewErrHandler(errors.New("[-e|--enable] and [-d|--disable] are mutually exclusive"), true)
+		}
+		exemptRoleIDs, err := ctx.RoleList("exempt")
+		if err != nil {
+			_, isREST := err.(*discordgo.RESTError)
+			return commands.NewErrHandler(err, !isREST)
+		}
+		for _, roleID := range exemptRoleIDs {
+			_, err := bot.guildRole(e.GuildID, roleID)
+			if err != nil {
+				return commands.NewErrHandler(err, IsNotFound(err))
+			}
+		}
+
+		bot.mu.Lock()
+		policy := &bot.getGuildUsosInfo(e.GuildID).UnverifiedPolicy
+		if ctx.Flag("enable") {
+			policy.Enabled = true
+		}
+		if ctx.Flag("disable") {
+			policy.Enabled = false
+		}
+		if ctx.Int("remind-after") >= 0 {
+			policy.RemindAfterDays = ctx.Int("remind-after")
+		}
+		if ctx.Int("kick-after") >= 0 {
+			policy.KickAfterDays = ctx.Int("kick-after")
+		}
+		if len(exemptRoleIDs) > 0 {
+			policy.ExemptRoleIDs = make(map[string]bool)
+			for _, roleID := range exemptRoleIDs {
+				policy.ExemptRoleIDs[roleID] = true
+			}
+		}
+
+		msg := "Unverified members are neither reminded nor kicked"
+		if policy.Enabled {
+			msg = fmt.Sprintf("Unverified members are reminded after %d days and kicked after %d days (0 - never)",
+				policy.RemindAfterDays, policy.KickAfterDays)
+			for roleID := range policy.ExemptRoleIDs {
+				msg += fmt.Sprintf(", <@&%s> exempt", roleID)
+			}
+		}
+		bot.mu.Unlock()
+		_, err = bot.ChannelMessageSend(e.ChannelID, msg)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	remindersCmd := parser.NewCommand("reminders", "manage reminders about pending verifications")
+	remindersCmd.PrivilagesRequired = true
+	err = remindersCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+	remindersCmd.Flag("e", "enable", &commands.Options{Required: false,
+		Help: "remind users about their pending verifications"})
+	remindersCmd.Flag("d", "disable", &commands.Options{Required: false,
+		Help: "do not remind users about their pending verifications"})
+	remindersCmd.StringList("a", "after", &commands.Options{Required: false,
+		Help: "durations after registration at which reminders are sent, e.g. 1h 24h, replace the current ones"})
+	remindersCmd.String("t", "text", &commands.Options{Required: false,
+		Help: "text of the reminders"})
+	remindersCmd.AddExample(`-e -a 1h -a 24h`)
+	remindersCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		if ctx.Flag("enable") && ctx.Flag("disable") {
+			return commands.NewErrHandler(errors.New("[-e|--enable] and [-d|--disable] are mutually exclusive"), true)
+		}
+		delays := make([]time.Duration, 0, len(ctx.StringList("after")))
+		for _, delayStr := range ctx.StringList("after") {
+			delay, err := time.ParseDuration(delayStr)
+			if err != nil {
+				return commands.NewErrHandler(err, true)
+			}
+			if delay <= 0 {
+				return commands.NewErrHandler(fmt.Errorf("delay %s is not positive", delayStr), true)
+			}
+			delays = append(delays, delay)
+		}
+		sort.Slice(delays, func(i, j int) bool { return delays[i] < delays[j] })
+
+		bot.mu.Lock()
+		policy := &bot.getGuildUsosInfo(e.GuildID).Reminders
+		if ctx.Flag("enable") {
+			policy.Enabled = true
+		}
+		if ctx.Flag("disable") {
+			policy.Enabled = false
+		}
+		if len(delays) > 0 {
+			policy.Delays = delays
+		}
+		if ctx.String("text") != "" {
+			policy.Text = ctx.String("text")
+		}
+
+		msg := "Users are not reminded about their pending verifications"
+		if policy.Enabled {
+			delayStrs := make([]string, 0, len(policy.delays()))
+			for _, delay := range policy.delays() {
+				delayStrs = append(delayStrs, delay.String())
+			}
+			msg = fmt.Sprintf("Users are reminded about their pending verifications after %s",
+				strings.Join(delayStrs, ", "))
+			if policy.Text != "" {
+				msg += fmt.Sprintf(" with text: %s", policy.Text)
+			}
+		}
+		bot.mu.Unlock()
+		_, err := bot.ChannelMessageSend(e.ChannelID, msg)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	setupCmd := parser.NewCommand("setup", "set the server up for usos authorization")
+	setupCmd.PrivilagesRequired = true
+	err = setupCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+
+	gateSetupCmd := setupCmd.NewCommand("gate", "lock the server's channels behind the authorize role, "+
+		"changes are applied after confirmation")
+	gateSetupCmd.Channel("w", "welcome", &commands.Options{Required: true,
+		Help: "the channel @everyone can read and get authorized in"})
+	gateSetupCmd.StringList("c", "categories", &commands.Options{Required: false,
+		Help: "categories only the authorize role can view given by mentions, IDs or names, all channels if omitted"})
+	gateSetupCmd.Role("r", "role", &commands.Options{Required: false,
+		Help: "an existing role to take over as the authorize role"})
+	gateSetupCmd.String("m", "msg", &commands.Options{Required: false,
+		Help: "custom prompt on the authorize message"})
+	gateSetupCmd.AddExample(`-w #welcome -c Courses -r Verified`)
+	gateSetupCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		prompt := ctx.String("msg")
+		if prompt == "" {
+			prompt = "Click the button below to get authorized!"
+		}
+		categoryIDs, err := ctx.ChannelList("categories")
+		if err != nil {
+			_, isREST := err.(*discordgo.RESTError)
+			return commands.NewErrHandler(err, !isREST)
+		}
+		plan, err := bot.planGate(e.GuildID, ctx.String("welcome"), categoryIDs, ctx.String("role"), prompt)
+		if err != nil {
+			return commands.NewErrHandler(err, IsNotFound(err))
+		}
+		channels, err := bot.GuildChannels(e.GuildID)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		bot.mu.Lock()
+		description := bot.describeGatePlan(e.GuildID, plan, channels)
+		bot.mu.Unlock()
+		_, err = bot.ChannelMessageSend(e.ChannelID, description)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	confirmSetupCmd := setupCmd.NewCommand("confirm", "apply the setup awaiting confirmation")
+	confirmSetupCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		plan, err := bot.pendingGatePlan(e.GuildID)
+		delete(bot.setupPlans, e.GuildID)
+		bot.mu.Unlock()
+		if err != nil {
+			return commands.NewErrHandler(err, true)
 		}
-		err := bot.finalizeAuthorization(e.Author, *verifier)
-		switch err.(type) {
-		case *ErrUnregisteredUnauthorizedUser, *ErrFilteredOut, *usos.ErrUnableToCall, *ErrRoleNotFound, *ErrWrongVerifier:
+		err = bot.applyGatePlan(e.GuildID, plan)
+		if err != nil {
+			return commands.NewErrHandler(err, IsNotFound(err))
+		}
+		_, err = bot.ChannelMessageSend(e.ChannelID, "Setup applied successfully")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	cancelSetupCmd := setupCmd.NewCommand("cancel", "discard the setup awaiting confirmation")
+	cancelSetupCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		_, err := bot.pendingGatePlan(e.GuildID)
+		delete(bot.setupPlans, e.GuildID)
+		bot.mu.Unlock()
+		if err != nil {
 			return commands.NewErrHandler(err, true)
-		case nil:
-			err = bot.privMsgDiscord(e.Author.ID, "Authorization complete")
+		}
+		_, err = bot.ChannelMessageSend(e.ChannelID, "Setup cancelled")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	fallbackCmd := parser.NewCommand("fallback",
+		"manage the channel in which members not accepting private messages get them in private threads")
+	fallbackCmd.PrivilagesRequired = true
+	err = fallbackCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+	fallbackCmd.Channel("i", "id", &commands.Options{Required: false,
+		Help: "the fallback channel"})
+	fallbackCmd.Flag("d", "disable", &commands.Options{Required: false,
+		Help: "do not open private threads, only report failed private messages to the log channels"})
+	fallbackCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		if ctx.String("id") != "" && ctx.Flag("disable") {
+			return commands.NewErrHandler(errors.New("[-i|--id] and [-d|--disable] are mutually exclusive"), true)
+		}
+		if ctx.String("id") != "" {
+			channel, err := bot.Channel(ctx.String("id"))
 			if err != nil {
+				if IsNotFound(err) {
+					return commands.NewErrHandler(newErrChannelNotFound(err, ctx.String("id")), true)
+				}
 				return commands.NewErrHandler(err, false)
 			}
-			return nil
+			if channel.GuildID != e.GuildID || channel.Type != discordgo.ChannelTypeGuildText {
+				return commands.NewErrHandler(newErrChannelNotFound(nil, ctx.String("id")), true)
+			}
+		}
+
+		bot.mu.Lock()
+		guildInfo := bot.getGuildUsosInfo(e.GuildID)
+		if ctx.String("id") != "" {
+			guildInfo.DMFallbackChannelID = ctx.String("id")
+		}
+		if ctx.Flag("disable") {
+			guildInfo.DMFallbackChannelID = ""
+		}
+
+		msg := "Members not accepting private messages are only reported to the log channels"
+		if guildInfo.DMFallbackChannelID != "" {
+			msg = fmt.Sprintf("Members not accepting private messages get them in private threads in <#%s>",
+				guildInfo.DMFallbackChannelID)
+		}
+		bot.mu.Unlock()
+		_, err := bot.ChannelMessageSend(e.ChannelID, msg)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	prefixCmd := parser.NewCommand("prefix", "manage the prefix of commands on this server, mentioning the bot works too")
+	prefixCmd.PrivilagesRequired = true
+	err = prefixCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+	prefixCmd.String("s", "set", &commands.Options{Required: false,
+		Help: "the new prefix"})
+	prefixCmd.Flag("r", "reset", &commands.Options{Required: false,
+		Help: "use the default prefix " + defaultCommandPrefix})
+	prefixCmd.AddExample("-s ?")
+	prefixCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		if ctx.String("set") != "" && ctx.Flag("reset") {
+			return commands.NewErrHandler(errors.New("[-s|--set] and [-r|--reset] are mutually exclusive"), true)
+		}
+		if ctx.String("set") != "" {
+			err := validateCommandPrefix(ctx.String("set"))
+			if err != nil {
+				return commands.NewErrHandler(err, true)
+			}
+		}
+
+		bot.mu.Lock()
+		guildInfo := bot.getGuildUsosInfo(e.GuildID)
+		if ctx.String("set") != "" {
+			guildInfo.CommandPrefix = ctx.String("set")
+		}
+		if ctx.Flag("reset") {
+			guildInfo.CommandPrefix = ""
+		}
+		prefix := bot.commandPrefix(e.GuildID)
+		bot.mu.Unlock()
+
+		_, err := bot.ChannelMessageSend(e.ChannelID, fmt.Sprintf("Commands on this server start with %s or <@%s>",
+			utils.DiscordCodeSpan(prefix), bot.State.User.ID))
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	aliasCmd := parser.NewCommand("alias", "manage aliases of commands on this server")
+	aliasCmd.PrivilagesRequired = true
+	err = aliasCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+
+	addAliasCmd := aliasCmd.NewCommand("add", "add an alias standing for a command")
+	addAliasCmd.String("a", "alias", &commands.Options{Required: true,
+		Help: "the alias, a single word"})
+	addAliasCmd.String("c", "command", &commands.Options{Required: true,
+		Help: "the command the alias stands for, subcommands separated with spaces"})
+	addAliasCmd.AddExample("-a f -c filter")
+	addAliasCmd.AddExample(`-a fa -c "filter add"`)
+	addAliasCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		err := bot.addCommandAlias(e.GuildID, ctx.String("alias"), ctx.String("command"))
+		bot.mu.Unlock()
+		switch err.(type) {
+		case nil:
+			// no-op
+		case *ErrInvalidCommandAlias, *ErrCommandAliasTaken, *ErrCommandNotFound:
+			return commands.NewErrHandler(err, true)
 		default:
 			return commands.NewErrHandler(err, false)
 		}
+		_, err = bot.ChannelMessageSend(e.ChannelID, "Successfully added the alias")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	removeAliasCmd := aliasCmd.NewCommand("remove", "remove an alias")
+	removeAliasCmd.String("a", "alias", &commands.Options{Required: true,
+		Help: "the alias"})
+	removeAliasCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		err := bot.removeCommandAlias(e.GuildID, ctx.String("alias"))
+		bot.mu.Unlock()
+		if err != nil {
+			return commands.NewErrHandler(err, true)
+		}
+		_, err = bot.ChannelMessageSend(e.ChannelID, "Successfully removed the alias")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	listAliasCmd := aliasCmd.NewCommand("list", "list aliases")
+	listAliasCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		aliases := bot.getGuildUsosInfo(e.GuildID).CommandAliases
+		names := make([]string, 0, len(aliases))
+		for alias := range aliases {
+			names = append(names, alias)
+		}
+		sort.Strings(names)
+
+		msg := "Aliases:"
+		for _, alias := range names {
+			msg += fmt.Sprintf("\n%s - %s", utils.DiscordCodeSpan(alias), utils.DiscordCodeSpan(aliases[alias]))
+		}
+		if len(aliases) == 0 {
+			msg = "No aliases on this server."
+		}
+		bot.mu.Unlock()
+		err := bot.channelMessageSendLong(e.ChannelID, msg)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	permsCmd := parser.NewCommand("perms", "manage roles and users allowed to run commands requiring privilages")
+	permsCmd.PrivilagesRequired = true
+	err = permsCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+
+	grantPermsCmd := permsCmd.NewCommand("grant", "allow a role or a user to run a command or a group of commands")
+	grantPermsCmd.String("c", "command", &commands.Options{Required: true,
+		Help: "the command or the group of commands, subcommands separated with spaces"})
+	grantPermsCmd.Role("r", "role", &commands.Options{Required: false,
+		Help: "mention, ID or name of the role"})
+	grantPermsCmd.User("u", "user", &commands.Options{Required: false,
+		Help: "mention, ID or name of the user"})
+	grantPermsCmd.AddExample(`-c filter -r "verification moderators"`)
+	grantPermsCmd.AddExample(`-c "pending cancel" -u @someone`)
+	grantPermsCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		if ctx.String("role") == "" && ctx.String("user") == "" {
+			return commands.NewErrHandler(errors.New("[-r|--role] or [-u|--user] is required"), true)
+		}
+		err := bot.grantCommand(e.GuildID, ctx.String("command"), ctx.String("role"), ctx.String("user"))
+		if err != nil {
+			if IsNotFound(err) {
+				return commands.NewErrHandler(err, true)
+			}
+			return commands.NewErrHandler(err, false)
+		}
+		_, err = bot.ChannelMessageSend(e.ChannelID, "Successfully granted the command")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	revokePermsCmd := permsCmd.NewCommand("revoke", "take back a command or a group of commands from a role or a user")
+	revokePermsCmd.String("c", "command", &commands.Options{Required: true,
+		Help: "the command or the group of commands, as it was granted"})
+	revokePermsCmd.Role("r", "role", &commands.Options{Required: false,
+		Help: "mention, ID or name of the role"})
+	revokePermsCmd.User("u", "user", &commands.Options{Required: false,
+		Help: "mention, ID or name of the user"})
+	revokePermsCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		if (ctx.String("role") == "") == (ctx.String("user") == "") {
+			return commands.NewErrHandler(errors.New("exactly one of [-r|--role] and [-u|--user] is required"), true)
+		}
+		bot.mu.Lock()
+		err := bot.revokeCommand(e.GuildID, ctx.String("command"), ctx.String("role"), ctx.String("user"))
+		bot.mu.Unlock()
+		if err != nil {
+			return commands.NewErrHandler(err, true)
+		}
+		_, err = bot.ChannelMessageSend(e.ChannelID, "Successfully revoked the command")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	listPermsCmd := permsCmd.NewCommand("list", "list commands granted to roles and users")
+	listPermsCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		msg := "No commands granted, only administrators may run commands requiring privilages."
+		bot.mu.Lock()
+		description := bot.describeCommandPermissions(e.GuildID)
+		bot.mu.Unlock()
+		if description != "" {
+			msg = "Granted commands:\n" + description
+		}
+		err := bot.channelMessageSendLong(e.ChannelID, msg)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
 	}
 
 	logChannelCmd := parser.NewCommand("log", "manage discord log channels")
@@ -88,14 +1304,16 @@ func (bot *UsosBot) setupCommandParser() (*commands.DiscordParser, error) {
 	}
 
 	addLogChannelCmd := logChannelCmd.NewCommand("add", "Add a new log channel to this server")
-	logChannelID := addLogChannelCmd.String("i", "id",
-		&argparse.Options{Required: false,
-			Help: "channel id, defaults to channel in which the command was called"})
-	addLogChannelCmd.Handler = func(cmd *commands.DiscordCommand, e *discordgo.MessageCreate) *commands.ErrHandler {
-		if *logChannelID == "" {
-			*logChannelID = e.ChannelID
+	addLogChannelCmd.Channel("i", "id",
+		&commands.Options{Required: false,
+			Help: "the channel, defaults to channel in which the command was called"})
+	addLogChannelCmd.AddExample(`-i #usos-log`)
+	addLogChannelCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		channelID := ctx.String("id")
+		if channelID == "" {
+			channelID = e.ChannelID
 		}
-		err := bot.addLogChannel(e.GuildID, *logChannelID)
+		err := bot.addLogChannel(e.GuildID, channelID)
 		if err != nil {
 			return commands.NewErrHandler(err, true)
 		}
@@ -104,14 +1322,17 @@ func (bot *UsosBot) setupCommandParser() (*commands.DiscordParser, error) {
 	}
 
 	removeLogChannelCmd := logChannelCmd.NewCommand("remove", "Remove a log channel from this server")
-	logChannelIDToRemove := removeLogChannelCmd.String("i", "id",
-		&argparse.Options{Required: false,
-			Help: "channel id, defaults to channel in which the command was called"})
-	removeLogChannelCmd.Handler = func(cmd *commands.DiscordCommand, e *discordgo.MessageCreate) *commands.ErrHandler {
-		if *logChannelIDToRemove == "" {
-			*logChannelIDToRemove = e.ChannelID
+	removeLogChannelCmd.Channel("i", "id",
+		&commands.Options{Required: false,
+			Help: "the channel, defaults to channel in which the command was called"})
+	removeLogChannelCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		channelID := ctx.String("id")
+		if channelID == "" {
+			channelID = e.ChannelID
 		}
-		err := bot.removeLogChannel(e.GuildID, *logChannelIDToRemove)
+		bot.mu.Lock()
+		err := bot.removeLogChannel(e.GuildID, channelID)
+		bot.mu.Unlock()
 		if err != nil {
 			return commands.NewErrHandler(err, true)
 		}
@@ -120,9 +1341,11 @@ func (bot *UsosBot) setupCommandParser() (*commands.DiscordParser, error) {
 	}
 
 	listLogChannelCmd := logChannelCmd.NewCommand("list", "List log channels bound to this server")
-	listLogChannelCmd.Handler = func(cmd *commands.DiscordCommand, e *discordgo.MessageCreate) *commands.ErrHandler {
-		guildInfo := bot.getGuildUsosInfo(e.GuildID)
-		if len(guildInfo.LogChannelIDs) == 0 {
+	listLogChannelCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		channelIDs := bot.logChannelIDs(e.GuildID)
+		bot.mu.Unlock()
+		if len(channelIDs) == 0 {
 			msg := "No log channels on this servers set yet."
 			_, err := bot.ChannelMessageSend(e.ChannelID, msg)
 			if err != nil {
@@ -130,8 +1353,8 @@ func (bot *UsosBot) setupCommandParser() (*commands.DiscordParser, error) {
 			}
 			return nil
 		}
-		channels := make([]*discordgo.Channel, 0, len(guildInfo.LogChannelIDs))
-		for channelID := range guildInfo.LogChannelIDs {
+		channels := make([]*discordgo.Channel, 0, len(channelIDs))
+		for _, channelID := range channelIDs {
 			channel, err := bot.getLogChannel(e.GuildID, channelID)
 			if err != nil {
 				if IsNotFound(err) {
@@ -162,19 +1385,20 @@ func (bot *UsosBot) setupCommandParser() (*commands.DiscordParser, error) {
 	if err != nil {
 		return nil, err
 	}
-	roleID := roleCmd.String("i", "id", &argparse.Options{Required: true,
+	roleCmd.Role("i", "id", &commands.Options{Required: true,
 		Help: "set the server's authorization role"})
-	roleCmd.Handler = func(cmd *commands.DiscordCommand, e *discordgo.MessageCreate) *commands.ErrHandler {
-		guildInfo := bot.getGuildUsosInfo(e.GuildID)
-
+	roleCmd.AddExample(`-i @Student`)
+	roleCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
 		roles, err := bot.GuildRoles(e.GuildID)
 		if err != nil {
 			return commands.NewErrHandler(err, false)
 		}
 
 		for _, role := range roles {
-			if role.ID == *roleID {
-				guildInfo.AuthorizeRoleID = *roleID
+			if role.ID == ctx.String("id") {
+				bot.mu.Lock()
+				bot.getGuildUsosInfo(e.GuildID).AuthorizeRoleID = ctx.String("id")
+				bot.mu.Unlock()
 				_, err = bot.ChannelMessageSend(e.ChannelID, "Authorization role ID set successfully")
 				if err != nil {
 					return commands.NewErrHandler(err, false)
@@ -183,7 +1407,7 @@ func (bot *UsosBot) setupCommandParser() (*commands.DiscordParser, error) {
 			}
 		}
 
-		err = newErrRoleNotFound(*roleID, e.GuildID)
+		err = newErrRoleNotFound(ctx.String("id"), e.GuildID)
 		return commands.NewErrHandler(err, true)
 	}
 
@@ -195,33 +1419,24 @@ func (bot *UsosBot) setupCommandParser() (*commands.DiscordParser, error) {
 	}
 
 	addFilterCmd := filterCmd.NewCommand("add", "add usos filter; user has to pass at least one of the filters to get past the authorization successfully")
-	programmes := addFilterCmd.StringList("p", "programme", &argparse.Options{Required: false,
+	addFilterCmd.StringList("p", "programme", &commands.Options{Required: false,
 		Help: "Programme names which the user is required too have (all) to pass."})
-	courses := addFilterCmd.StringList("c", "course", &argparse.Options{Required: false,
+	addFilterCmd.StringList("c", "course", &commands.Options{Required: false,
 		Help: "Course IDs which the user is required too have (all) to pass."})
-	addFilterCmd.Handler = func(cmd *commands.DiscordCommand, e *discordgo.MessageCreate) *commands.ErrHandler {
-		if len(*programmes) == 0 && len(*courses) == 0 {
-			return commands.NewErrHandler(newErrFilterEmpty(), true)
-		}
-
-		usosProrammes := make([]*usos.Programme, len(*programmes))
-		for i, programme := range *programmes {
-			usosProrammes[i] = &usos.Programme{Name: programme}
-		}
-		usosCourses := make([]*usos.Course, len(*courses))
-		for i, course := range *courses {
-			usosCourses[i] = &usos.Course{ID: course}
-		}
-
-		filter := &usos.User{
-			Programmes: usosProrammes,
-			Courses:    usosCourses,
+	addFilterCmd.AddExample(`-p "Computer Science"`)
+	addFilterCmd.AddExample(`-c 1000-IN1PRM`)
+	addFilterCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		filter, err := newUsosFilter(ctx.StringList("programme"), ctx.StringList("course"), nil, nil, usos.StaffStatusNone)
+		if err != nil {
+			return commands.NewErrHandler(err, true)
 		}
 
+		bot.mu.Lock()
 		guildInfo := bot.getGuildUsosInfo(e.GuildID)
 		guildInfo.Filters = append(guildInfo.Filters, filter)
+		bot.mu.Unlock()
 
-		_, err := bot.ChannelMessageSend(e.ChannelID, "Filter added successfully")
+		_, err = bot.ChannelMessageSend(e.ChannelID, "Filter added successfully")
 		if err != nil {
 			return commands.NewErrHandler(err, false)
 		}
@@ -229,14 +1444,18 @@ func (bot *UsosBot) setupCommandParser() (*commands.DiscordParser, error) {
 	}
 
 	removeFilterCmd := filterCmd.NewCommand("remove", "remove an existing filter")
-	removeFilterID := removeFilterCmd.Int("i", "id", &argparse.Options{Required: true,
-		Help: fmt.Sprintf("Filter's id, can be obtained using the %s command", utils.DiscordCodeSpan("!usos filter list"))})
-	removeFilterCmd.Handler = func(cmd *commands.DiscordCommand, e *discordgo.MessageCreate) *commands.ErrHandler {
+	removeFilterCmd.Int("i", "id", &commands.Options{Required: true,
+		Help: fmt.Sprintf("Filter's id, can be obtained using the %s command", utils.DiscordCodeSpan("filter list"))})
+	removeFilterCmd.AddExample(`-i 1`)
+	removeFilterCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
 		guildInfo := bot.getGuildUsosInfo(e.GuildID)
-		if *removeFilterID < 1 || *removeFilterID > len(guildInfo.Filters) {
-			return commands.NewErrHandler(newErrFilterNotFound(*removeFilterID), true)
+		if ctx.Int("id") < 1 || ctx.Int("id") > len(guildInfo.Filters) {
+			bot.mu.Unlock()
+			return commands.NewErrHandler(newErrFilterNotFound(ctx.Int("id")), true)
 		}
-		guildInfo.Filters = append(guildInfo.Filters[:*removeFilterID-1], guildInfo.Filters[*removeFilterID:]...)
+		guildInfo.Filters = append(guildInfo.Filters[:ctx.Int("id")-1], guildInfo.Filters[ctx.Int("id"):]...)
+		bot.mu.Unlock()
 
 		_, err := bot.ChannelMessageSend(e.ChannelID, "Filter removed successfully")
 		if err != nil {
@@ -247,9 +1466,11 @@ func (bot *UsosBot) setupCommandParser() (*commands.DiscordParser, error) {
 	}
 
 	listFilterCmd := filterCmd.NewCommand("list", "list usos filters")
-	listFilterCmd.Handler = func(cmd *commands.DiscordCommand, e *discordgo.MessageCreate) *commands.ErrHandler {
-		guildInfo := bot.getGuildUsosInfo(e.GuildID)
-		if len(guildInfo.Filters) == 0 {
+	listFilterCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		filters := append([]*usos.User(nil), bot.getGuildUsosInfo(e.GuildID).Filters...)
+		bot.mu.Unlock()
+		if len(filters) == 0 {
 			_, err := bot.ChannelMessageSend(e.ChannelID, "No filters set yet, all usos-verified users are let through.")
 			if err != nil {
 				return commands.NewErrHandler(err, false)
@@ -263,7 +1484,7 @@ func (bot *UsosBot) setupCommandParser() (*commands.DiscordParser, error) {
 		}
 
 		msg := fmt.Sprintf("%s's Filters:", utils.DiscordBold(guild.Name))
-		for i, filter := range guildInfo.Filters {
+		for i, filter := range filters {
 			body, err := json.MarshalIndent(filter, "", "  ")
 			if err != nil {
 				return commands.NewErrHandler(err, false)
@@ -277,5 +1498,261 @@ func (bot *UsosBot) setupCommandParser() (*commands.DiscordParser, error) {
 		return nil
 	}
 
+	roleRuleCmd := parser.NewCommand("rolerule", "manage roles given automatically based on usos data")
+	roleRuleCmd.PrivilagesRequired = true
+	err = roleRuleCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+
+	addRoleRuleCmd := roleRuleCmd.NewCommand("add", "add a role rule; authorized users get the role if they pass its filter")
+	addRoleRuleCmd.Role("r", "role", &commands.Options{Required: true,
+		Help: "the role to give"})
+	addRoleRuleCmd.StringList("p", "programme", &commands.Options{Required: false,
+		Help: "Programme names which the user is required to have (all) to pass."})
+	addRoleRuleCmd.StringList("c", "course", &commands.Options{Required: false,
+		Help: "Course IDs which the user is required to have (all) to pass."})
+	addRoleRuleCmd.StringList("t", "term", &commands.Options{Required: false,
+		Help: "Term IDs in which the user is required to have courses (all) to pass."})
+	addRoleRuleCmd.StringList("g", "group", &commands.Options{Required: false,
+		Help: "Class groups (<course id>:<group number>) which the user is required to attend (all) to pass."})
+	addRoleRuleCmd.Int("s", "staff", &commands.Options{Required: false,
+		Help: "Staff status the user is required to have to pass: 1 - employee, 2 - academic teacher."})
+	addRoleRuleCmd.AddExample(`-r @CS -p "Computer Science"`)
+	addRoleRuleCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		_, err := bot.guildRole(e.GuildID, ctx.String("role"))
+		if err != nil {
+			if IsNotFound(err) {
+				return commands.NewErrHandler(err, true)
+			}
+			return commands.NewErrHandler(err, false)
+		}
+
+		filter, err := newUsosFilter(ctx.StringList("programme"), ctx.StringList("course"), ctx.StringList("term"), ctx.StringList("group"), ctx.Int("staff"))
+		if err != nil {
+			return commands.NewErrHandler(err, true)
+		}
+
+		bot.mu.Lock()
+		guildInfo := bot.getGuildUsosInfo(e.GuildID)
+		guildInfo.RoleRules = append(guildInfo.RoleRules, &roleRule{RoleID: ctx.String("role"), Filter: filter})
+		bot.mu.Unlock()
+
+		_, err = bot.ChannelMessageSend(e.ChannelID, "Role rule added successfully")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	removeRoleRuleCmd := roleRuleCmd.NewCommand("remove", "remove an existing role rule")
+	removeRoleRuleCmd.Int("i", "id", &commands.Options{Required: true,
+		Help: fmt.Sprintf("Role rule's id, can be obtained using the %s command", utils.DiscordCodeSpan("rolerule list"))})
+	removeRoleRuleCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		guildInfo := bot.getGuildUsosInfo(e.GuildID)
+		if ctx.Int("id") < 1 || ctx.Int("id") > len(guildInfo.RoleRules) {
+			bot.mu.Unlock()
+			return commands.NewErrHandler(newErrRoleRuleNotFound(ctx.Int("id")), true)
+		}
+		guildInfo.RoleRules = append(guildInfo.RoleRules[:ctx.Int("id")-1], guildInfo.RoleRules[ctx.Int("id"):]...)
+		bot.mu.Unlock()
+
+		_, err := bot.ChannelMessageSend(e.ChannelID, "Role rule removed successfully")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	listRoleRuleCmd := roleRuleCmd.NewCommand("list", "list role rules")
+	listRoleRuleCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		roleRules := append([]*roleRule(nil), bot.getGuildUsosInfo(e.GuildID).RoleRules...)
+		bot.mu.Unlock()
+		if len(roleRules) == 0 {
+			_, err := bot.ChannelMessageSend(e.ChannelID, "No role rules set yet, authorized users get only the authorization role.")
+			if err != nil {
+				return commands.NewErrHandler(err, false)
+			}
+			return nil
+		}
+
+		guild, err := bot.Guild(e.GuildID)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+
+		msg := fmt.Sprintf("%s's Role rules:", utils.DiscordBold(guild.Name))
+		for i, rule := range roleRules {
+			body, err := json.MarshalIndent(rule.Filter, "", "  ")
+			if err != nil {
+				return commands.NewErrHandler(err, false)
+			}
+			msg += fmt.Sprintf("\n%d. <@&%s>", i+1, rule.RoleID) + utils.DiscordCodeBlock(string(body), "json")
+		}
+		_, err = bot.ChannelMessageSend(e.ChannelID, msg)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	coursesCmd := parser.NewCommand("courses", "manage roles and channels created automatically for usos courses")
+	coursesCmd.PrivilagesRequired = true
+	err = coursesCmd.SetScope(commands.ScopeGuild)
+	if err != nil {
+		return nil, err
+	}
+
+	configCoursesCmd := coursesCmd.NewCommand("config", "configure provisioning of course roles and channels")
+	configCoursesCmd.Flag("e", "enable", &commands.Options{Required: false,
+		Help: "enable provisioning"})
+	configCoursesCmd.Flag("d", "disable", &commands.Options{Required: false,
+		Help: "disable provisioning, already provisioned courses are kept"})
+	configCoursesCmd.Channel("c", "category", &commands.Options{Required: false,
+		Help: "the category to create course channels in"})
+	configCoursesCmd.Channel("a", "archive-category", &commands.Options{Required: false,
+		Help: "the category to move channels of ended courses to"})
+	configCoursesCmd.Int("m", "min-members", &commands.Options{Required: false,
+		Help:    "provision courses attended by at least that many verified members, 0 provisions only approved courses",
+		Default: -1})
+	configCoursesCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		if ctx.Flag("enable") && ctx.Flag("disable") {
+			return commands.NewErrHandler(errors.New("[-e|--enable] and [-d|--disable] are mutually exclusive"), true)
+		}
+		for _, categoryID := range []string{ctx.String("category"), ctx.String("archive-category")} {
+			if categoryID == "" {
+				continue
+			}
+			_, err := bot.guildCategory(e.GuildID, categoryID)
+			if err != nil {
+				if IsNotFound(err) {
+					return commands.NewErrHandler(err, true)
+				}
+				return commands.NewErrHandler(err, false)
+			}
+		}
+
+		bot.mu.Lock()
+		provisioning := &bot.getGuildUsosInfo(e.GuildID).CourseProvisioning
+		if ctx.Flag("enable") {
+			provisioning.Enabled = true
+		}
+		if ctx.Flag("disable") {
+			provisioning.Enabled = false
+		}
+		if ctx.String("category") != "" {
+			provisioning.CategoryID = ctx.String("category")
+		}
+		if ctx.String("archive-category") != "" {
+			provisioning.ArchiveCategoryID = ctx.String("archive-category")
+		}
+		if ctx.Int("min-members") >= 0 {
+			provisioning.MinMembers = ctx.Int("min-members")
+		}
+		msg := "Course provisioning is disabled"
+		if provisioning.Enabled {
+			msg = fmt.Sprintf("Course provisioning is enabled, minimum of verified members: %d", provisioning.MinMembers)
+		}
+		bot.mu.Unlock()
+
+		err := bot.provisionCourses(e.GuildID, "", nil)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		_, err = bot.ChannelMessageSend(e.ChannelID, msg)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	approveCoursesCmd := coursesCmd.NewCommand("approve", "approve courses to be provisioned regardless of their member count")
+	approveCoursesCmd.StringList("c", "course", &commands.Options{Required: true,
+		Help: "Course IDs to approve"})
+	approveCoursesCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		provisioning := &bot.getGuildUsosInfo(e.GuildID).CourseProvisioning
+		for _, courseID := range ctx.StringList("course") {
+			provisioning.Approved[courseID] = true
+		}
+		bot.mu.Unlock()
+
+		err := bot.provisionCourses(e.GuildID, "", nil)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		_, err = bot.ChannelMessageSend(e.ChannelID, "Courses approved successfully")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	unapproveCoursesCmd := coursesCmd.NewCommand("unapprove", "withdraw approval of courses")
+	unapproveCoursesCmd.StringList("c", "course", &commands.Options{Required: true,
+		Help: "Course IDs to withdraw approval of"})
+	unapproveCoursesCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		provisioning := &bot.getGuildUsosInfo(e.GuildID).CourseProvisioning
+		for _, courseID := range ctx.StringList("course") {
+			delete(provisioning.Approved, courseID)
+		}
+		bot.mu.Unlock()
+		_, err := bot.ChannelMessageSend(e.ChannelID, "Course approvals withdrawn successfully")
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
+	listCoursesCmd := coursesCmd.NewCommand("list", "list courses attended by verified members who linked their usos identities and their provisioning")
+	listCoursesCmd.Handler = func(ctx *commands.Context, e *discordgo.MessageCreate) *commands.ErrHandler {
+		bot.mu.Lock()
+		provisioning := &bot.getGuildUsosInfo(e.GuildID).CourseProvisioning
+		attendees, courses := bot.courseAttendees(e.GuildID)
+		for courseID := range provisioning.Approved {
+			if courses[courseID] == nil {
+				courses[courseID] = &provisionedCourse{}
+			}
+		}
+		if len(courses) == 0 {
+			bot.mu.Unlock()
+			_, err := bot.ChannelMessageSend(e.ChannelID, "No courses attended by verified members yet.")
+			if err != nil {
+				return commands.NewErrHandler(err, false)
+			}
+			return nil
+		}
+
+		courseIDs := make([]string, 0, len(courses))
+		for courseID := range courses {
+			courseIDs = append(courseIDs, courseID)
+		}
+		sort.Strings(courseIDs)
+
+		msg := "Courses:"
+		for _, courseID := range courseIDs {
+			msg += fmt.Sprintf("\n%s %s - %d verified members", utils.DiscordCodeSpan(courseID),
+				courses[courseID].Name, len(attendees[courseID]))
+			if provisioning.Approved[courseID] {
+				msg += ", approved"
+			}
+			if provisioned := provisioning.Courses[courseID]; provisioned != nil {
+				msg += fmt.Sprintf(", provisioned: <@&%s> <#%s>", provisioned.RoleID, provisioned.ChannelID)
+				if provisioned.Archived {
+					msg += " (archived)"
+				}
+			}
+		}
+		bot.mu.Unlock()
+		err := bot.channelMessageSendLong(e.ChannelID, msg)
+		if err != nil {
+			return commands.NewErrHandler(err, false)
+		}
+		return nil
+	}
+
 	return parser, nil
 }
@@ -0,0 +1,175 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Options represents options of a command's argument
+type Options struct {
+	Required bool
+	Help     string
+	Default  interface{} // value of the argument if it is not given, has to be of the argument's type
+}
+
+// argKind indicates the type of an argument's value
+type argKind int
+
+const (
+	kindFlag argKind = iota
+	kindString
+	kindInt
+	kindStringList
+	kindSelector
+	kindChannel
+	kindRole
+	kindUser
+	kindWords
+)
+
+// argument represents a declaration of a command's argument
+type argument struct {
+	sname   string
+	lname   string
+	kind    argKind
+	opts    Options
+	choices []string // allowed values of selectors
+}
+
+// name returns the argument's names as used in messages
+func (arg *argument) name() string {
+	if arg.sname == "" {
+		return "--" + arg.lname
+	}
+	return fmt.Sprintf("-%s|--%s", arg.sname, arg.lname)
+}
+
+// usage returns the argument's usage as used in the command's usage
+func (arg *argument) usage() string {
+	var usage string
+	switch arg.kind {
+	case kindFlag:
+		usage = arg.name()
+	case kindStringList:
+		usage = fmt.Sprintf(`%s "<value>" [...]`, arg.name())
+	case kindWords:
+		usage = fmt.Sprintf("<%s> [...]", arg.lname)
+	case kindSelector:
+		usage = fmt.Sprintf("%s (%s)", arg.name(), strings.Join(arg.choices, "|"))
+	case kindInt:
+		usage = fmt.Sprintf("%s <integer>", arg.name())
+	default:
+		usage = fmt.Sprintf(`%s "<value>"`, arg.name())
+	}
+	if !arg.opts.Required {
+		usage = "[" + usage + "]"
+	}
+	return usage
+}
+
+// isMention checks if the argument's value is a mention resolved to an ID before handling
+func (arg *argument) isMention() bool {
+	return arg.kind == kindChannel || arg.kind == kindRole || arg.kind == kindUser
+}
+
+// Flag declares an argument which is true if it is given and false otherwise
+func (command *DiscordCommand) Flag(short string, long string, opts *Options) {
+	command.addArg(short, long, kindFlag, opts, nil)
+}
+
+// String declares an argument taking a string
+func (command *DiscordCommand) String(short string, long string, opts *Options) {
+	command.addArg(short, long, kindString, opts, nil)
+}
+
+// Int declares an argument taking an integer
+func (command *DiscordCommand) Int(short string, long string, opts *Options) {
+	command.addArg(short, long, kindInt, opts, nil)
+}
+
+// StringList declares an argument taking a string, which can be given many times
+func (command *DiscordCommand) StringList(short string, long string, opts *Options) {
+	command.addArg(short, long, kindStringList, opts, nil)
+}
+
+// Words declares an argument collecting the words which follow the command and are not arguments,
+// a command can have only one such argument
+func (command *DiscordCommand) Words(long string, opts *Options) {
+	if command.wordsArg() != nil {
+		panic(fmt.Sprintf("command %s already collects words", command.name))
+	}
+	command.addArg("", long, kindWords, opts, nil)
+}
+
+// Selector declares an argument taking one of the given strings
+func (command *DiscordCommand) Selector(short string, long string, choices []string, opts *Options) {
+	command.addArg(short, long, kindSelector, opts, choices)
+}
+
+// Channel declares an argument taking a channel mention, ID or name, resolved to the channel's ID
+func (command *DiscordCommand) Channel(short string, long string, opts *Options) {
+	command.addArg(short, long, kindChannel, opts, nil)
+}
+
+// Role declares an argument taking a role mention, ID or name, resolved to the role's ID
+func (command *DiscordCommand) Role(short string, long string, opts *Options) {
+	command.addArg(short, long, kindRole, opts, nil)
+}
+
+// User declares an argument taking a user mention, ID or name, resolved to the user's ID
+func (command *DiscordCommand) User(short string, long string, opts *Options) {
+	command.addArg(short, long, kindUser, opts, nil)
+}
+
+// addArg declares the command's argument, panics if its names are taken or invalid
+// as declaring arguments is a programming matter
+func (command *DiscordCommand) addArg(short string, long string, kind argKind, opts *Options, choices []string) {
+	if long == "" || len(short) > 1 {
+		panic(fmt.Sprintf("command %s: invalid argument names %q and %q", command.name, short, long))
+	}
+	for _, arg := range command.args {
+		if arg.lname == long || short != "" && arg.sname == short {
+			panic(fmt.Sprintf("command %s: argument names %q and %q already taken", command.name, short, long))
+		}
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+	command.args = append(command.args, &argument{
+		sname:   short,
+		lname:   long,
+		kind:    kind,
+		opts:    *opts,
+		choices: choices,
+	})
+}
+
+// argByLong returns the command's argument with the given long name or nil if there is none
+func (command *DiscordCommand) argByLong(lname string) *argument {
+	for _, arg := range command.args {
+		if arg.lname == lname {
+			return arg
+		}
+	}
+	return nil
+}
+
+// argByShort returns the command's argument with the given short name or nil if there is none
+func (command *DiscordCommand) argByShort(sname string) *argument {
+	for _, arg := range command.args {
+		if arg.sname != "" && arg.sname == sname {
+			return arg
+		}
+	}
+	return nil
+}
+
+// wordsArg returns the command's argument collecting words or nil if there is none
+func (command *DiscordCommand) wordsArg() *argument {
+	for _, arg := range command.args {
+		if arg.kind == kindWords {
+			return arg
+		}
+	}
+	return nil
+}
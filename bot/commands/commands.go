@@ -1,86 +1,158 @@
 package commands
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/Ogurczak/discord-usos-auth/utils"
-	"github.com/akamensky/argparse"
 	"github.com/bwmarrin/discordgo"
 )
 
-// DiscordParser represents a parser for commands send through discord
+// DiscordParser represents a parser for commands send through discord.
+// The command tree is built once, every parse produces its own Context,
+// so the parser may be used concurrently once built.
 type DiscordParser struct {
-	*argparse.Parser
 	*DiscordCommand
-	helpSName  string
-	helpLName  string
-	parsedHelp bool
 }
 
 // DiscordCommand represents a command send through discord
 type DiscordCommand struct {
-	*argparse.Command
-
-	// Handler is the function executed during parsing this command
-	Handler func(*DiscordCommand, *discordgo.MessageCreate) *ErrHandler
-
+	// Handler is the function executed during handling this command
+	Handler func(*Context, *discordgo.MessageCreate) *ErrHandler
+	// SlashName names the slash subcommand taking the arguments of a command which has subcommands too,
+	// as slash commands cannot have both
+	SlashName string
+
+	name               string
+	description        string
 	session            *discordgo.Session
 	commands           []*DiscordCommand
+	args               []*argument
 	scope              CommandScope
 	PrivilagesRequired bool
 	parent             *DiscordCommand
+	examples           []string
 }
 
 // NewDiscordParser returns a new instance of DiscordParser Class
 func NewDiscordParser(name string, description string, session *discordgo.Session) *DiscordParser {
-	parser := new(DiscordParser)
-
-	parser.Parser = argparse.NewParser(name, description)
-
-	parser.DiscordCommand = newDiscordCommand(&parser.Parser.Command, session)
-
-	parser.helpSName = "h"
-	parser.helpLName = "help"
-
+	parser := &DiscordParser{
+		DiscordCommand: newDiscordCommand(name, description, session),
+	}
+	parser.newHelpCommand()
 	return parser
 }
 
-// Parse parses given string arguments and sends parsing errors to user with the given user ID.
-// See github.com/akamensky/argparse.Parser.Parse
-func (parser *DiscordParser) Parse(e *discordgo.MessageCreate) error {
-	args := strings.Fields(e.Content)
+// Parse tokenizes and parses the command following the invocation's prefix in the message,
+// sending parsing errors and help to the message's channel
+func (parser *DiscordParser) Parse(e *discordgo.MessageCreate, inv *Invocation) (*Context, error) {
+	command, ok := inv.Match(e.Content)
+	if !ok {
+		return nil, newErrParse(errors.New("the message does not start with the prefix"))
+	}
+	args, err := Tokenize(command)
+	if err != nil {
+		return nil, parser.sendParseErr(parser.DiscordCommand, newErrParse(err), e.ChannelID, inv, nil)
+	}
+	return parser.parse(inv.expand(parser, args), e, inv, nil)
+}
 
-	// replace help func
-	parser.setDiscordHelp(e.ChannelID)
+// ParseInteraction parses the slash command interaction the same way as an equivalent message
+// invoked by the invocation. Parsing errors are not sent to the interaction's channel,
+// they are left in the Usage of the returned ErrParse for the invoker only.
+func (parser *DiscordParser) ParseInteraction(e *discordgo.InteractionCreate, inv *Invocation) (*Context, error) {
+	author := e.User
+	if e.Member != nil {
+		author = e.Member.User
+	}
+	message := &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        e.ID,
+		ChannelID: e.ChannelID,
+		GuildID:   e.GuildID,
+		Author:    author,
+		Member:    e.Member,
+	}}
+	args, err := parser.InteractionArgs(e.ApplicationCommandData())
+	if err != nil {
+		return nil, parser.sendParseErr(parser.DiscordCommand, newErrParse(err), e.ChannelID, inv, e.Interaction)
+	}
+	return parser.parse(args, message, inv, e.Interaction)
+}
 
-	// original parse method
-	parseErr := parser.Parser.Parse(args)
-	if parseErr != nil {
-		parseErr = newErrParse(parseErr)
-		message := parser.youngestCommandHappened().Usage(parseErr.Error())
-		_, errSend := parser.session.ChannelMessageSend(e.ChannelID, message)
-		if errSend != nil {
-			return errSend
+// parse parses the arguments of the message following the prefix,
+// the interaction is nil unless the message stands for a slash command
+func (parser *DiscordParser) parse(args []string, e *discordgo.MessageCreate, inv *Invocation,
+	interaction *discordgo.Interaction) (*Context, error) {
+	cmd := parser.DiscordCommand
+	i := 0
+	for ; i < len(args); i++ {
+		subcommand := cmd.subcommand(args[i])
+		if subcommand == nil {
+			break
 		}
-		return parseErr
+		cmd = subcommand
 	}
 
-	// set parsedHelp
-	for _, arg := range args {
-		if arg == "-"+parser.helpSName || "--"+arg == parser.helpLName {
-			parser.parsedHelp = true
-			break
+	ctx := newContext(cmd, e, inv)
+	ctx.Interaction = interaction
+	parseErr := ctx.parseArguments(args[i:])
+	if ctx.parsedHelp {
+		err := cmd.sendHelp(e, inv)
+		if errParse, ok := err.(*ErrParse); ok {
+			return nil, parser.sendParseErr(parser.DiscordCommand, errParse, e.ChannelID, inv, interaction)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return ctx, nil
+	}
+	// commands with arguments are executed themselves if no subcommand is given
+	if parseErr == nil && len(cmd.commands) > 0 && len(cmd.args) == 0 {
+		names := make([]string, 0, len(cmd.commands))
+		for _, subcommand := range cmd.commands {
+			names = append(names, subcommand.name)
 		}
+		parseErr = fmt.Errorf("one of the commands [%s] is required", strings.Join(names, "|"))
 	}
+	if parseErr != nil {
+		return nil, parser.sendParseErr(cmd, newErrParse(parseErr), e.ChannelID, inv, interaction)
+	}
+	return ctx, nil
+}
 
-	return nil
+// sendParseErr sends the parsing error with usage of the command to the channel and returns the error.
+// Errors of slash commands are only kept in the error's Usage, the interaction is answered privately.
+func (parser *DiscordParser) sendParseErr(cmd *DiscordCommand, parseErr *ErrParse, channelID string, inv *Invocation,
+	interaction *discordgo.Interaction) error {
+	parseErr.Usage = cmd.Usage(parseErr.Error(), inv.Prefix)
+	if interaction != nil {
+		return parseErr
+	}
+	_, errSend := parser.session.ChannelMessageSend(channelID, parseErr.Usage)
+	if errSend != nil {
+		return errSend
+	}
+	return parseErr
 }
 
-// Handle calls appropriate command handlers
-func (parser *DiscordParser) Handle(e *discordgo.MessageCreate) error {
-	// set youngest command
-	cmd := parser.youngestCommandHappened()
+// report sends the message to the channel the command was invoked in,
+// or leaves it in the context's Response if a slash command invoked it so only its invoker sees it
+func (ctx *Context) report(message string) error {
+	if ctx.Interaction != nil {
+		ctx.Response = message
+		return nil
+	}
+	_, err := ctx.Command.session.ChannelMessageSend(ctx.Message.ChannelID, message)
+	return err
+}
+
+// Handle calls the handler of the context's command
+func (parser *DiscordParser) Handle(ctx *Context) error {
+	cmd := ctx.Command
+	e := ctx.Message
 
 	// validate scopes
 	var scopeErr error
@@ -90,24 +162,22 @@ func (parser *DiscordParser) Handle(e *discordgo.MessageCreate) error {
 		scopeErr = cmd.validateScope(ScopeGuild)
 	}
 	if scopeErr != nil {
-		message := cmd.Usage(scopeErr.Error())
-		_, err := parser.session.ChannelMessageSend(e.ChannelID, message)
+		err := ctx.report(cmd.Usage(scopeErr.Error(), ctx.Invocation.Prefix))
 		if err != nil {
 			return err
 		}
 		return scopeErr
 	}
 
-	// validate privilages
+	// validate privilages, commands requiring them may be granted to roles and users
 	if cmd.PrivilagesRequired {
-		isPrivilaged, err := cmd.IsPrivilaged(e)
+		c, err := cmd.newCaller(e)
 		if err != nil {
 			return err
 		}
-		if !isPrivilaged {
+		if !c.mayRun(cmd, ctx.Invocation.Permissions) {
 			privilageErr := newErrUnprivilaged(e, cmd)
-			message := cmd.Usage(privilageErr.Error())
-			_, err := parser.session.ChannelMessageSend(e.ChannelID, message)
+			err := ctx.report(cmd.Usage(privilageErr.Error(), ctx.Invocation.Prefix))
 			if err != nil {
 				return err
 			}
@@ -115,12 +185,20 @@ func (parser *DiscordParser) Handle(e *discordgo.MessageCreate) error {
 		}
 	}
 
+	// resolve mentions
+	resolveErr := ctx.resolveMentions()
+	if resolveErr != nil {
+		if _, ok := resolveErr.(*discordgo.RESTError); ok {
+			return resolveErr
+		}
+		return parser.sendParseErr(cmd, newErrParse(resolveErr), e.ChannelID, ctx.Invocation, ctx.Interaction)
+	}
+
 	// execute handlers
-	handleErr := cmd.Handler(cmd, e)
+	handleErr := cmd.Handler(ctx, e)
 	if handleErr != nil {
 		if handleErr.RedirectToCallerChannel {
-			message := cmd.Usage(handleErr.Error())
-			_, err := parser.session.ChannelMessageSend(e.ChannelID, message)
+			err := ctx.report(cmd.Usage(handleErr.Error(), ctx.Invocation.Prefix))
 			if err != nil {
 				return err
 			}
@@ -133,23 +211,12 @@ func (parser *DiscordParser) Handle(e *discordgo.MessageCreate) error {
 	return nil
 }
 
-// SetHelp removes the previous help argument, and creates a new one with the desired sname/lname
-func (parser *DiscordParser) SetHelp(sname string, lname string) {
-	parser.helpSName = sname
-	parser.helpLName = lname
-	parser.Parser.SetHelp(sname, lname)
-}
-
-// ParsedHelp indicates if the help argument was parsed or not
-func (parser *DiscordParser) ParsedHelp() bool {
-	return parser.parsedHelp
-}
-
-// NewCommand will create a sub-command and propagate all necessary fields.
-// See github.com/akamensky/argparse.Command.NewCommand
+// NewCommand will create a sub-command and propagate all necessary fields
 func (command *DiscordCommand) NewCommand(name string, description string) *DiscordCommand {
-	argparseCommand := command.Command.NewCommand(name, description)
-	newCommand := newDiscordCommand(argparseCommand, command.session)
+	if command.subcommand(name) != nil {
+		panic(fmt.Sprintf("command %s already has a subcommand %s", command.name, name))
+	}
+	newCommand := newDiscordCommand(name, description, command.session)
 
 	newCommand.PrivilagesRequired = command.PrivilagesRequired
 	newCommand.scope = command.scope
@@ -161,26 +228,19 @@ func (command *DiscordCommand) NewCommand(name string, description string) *Disc
 	return newCommand
 }
 
-// newDiscordCommand returns a new discord command from a regular command
-func newDiscordCommand(command *argparse.Command, session *discordgo.Session) *DiscordCommand {
-	discordCommand := &DiscordCommand{
-		Command:  command,
-		commands: make([]*DiscordCommand, 0),
-		session:  session,
-		scope:    maxScope,
-		Handler:  func(cmd *DiscordCommand, e *discordgo.MessageCreate) *ErrHandler { return nil },
-	}
-	discordCommand.ExitOnHelp(false)
-	return discordCommand
+// newDiscordCommand returns a new discord command
+func newDiscordCommand(name string, description string, session *discordgo.Session) *DiscordCommand {
+	return &DiscordCommand{
+		name:        name,
+		description: description,
+		commands:    make([]*DiscordCommand, 0),
+		args:        make([]*argument, 0),
+		session:     session,
+		scope:       maxScope,
+		Handler:     func(ctx *Context, e *discordgo.MessageCreate) *ErrHandler { return nil },
+	}
 }
 
-// func (command *DiscordCommand) setUserIDRecursive(message *discordgo.MessageCreate) {
-// 	command.message = message
-// 	for _, command := range command.commands {
-// 		command.setUserIDRecursive(message)
-// 	}
-// }
-
 func (command *DiscordCommand) validateScope(demandedScope CommandScope) error {
 	if command.scope&demandedScope != demandedScope {
 		return newErrCommandInWrongScope(demandedScope, command)
@@ -188,6 +248,16 @@ func (command *DiscordCommand) validateScope(demandedScope CommandScope) error {
 	return nil
 }
 
+// GetName returns the command's name
+func (command *DiscordCommand) GetName() string {
+	return command.name
+}
+
+// GetDescription returns the command's description
+func (command *DiscordCommand) GetDescription() string {
+	return command.description
+}
+
 // GetScope returns the command's operating scope
 func (command *DiscordCommand) GetScope() CommandScope {
 	return command.scope
@@ -207,40 +277,82 @@ func (command *DiscordCommand) Session() *discordgo.Session {
 	return command.session
 }
 
-func (command *DiscordCommand) youngestCommandHappened() *DiscordCommand {
+// subcommand returns the command's subcommand with the given name or nil if there is none
+func (command *DiscordCommand) subcommand(name string) *DiscordCommand {
 	for _, cmd := range command.commands {
-		if cmd.Happened() {
-			return cmd.youngestCommandHappened()
+		if cmd.name == name {
+			return cmd
 		}
 	}
-	return command
+	return nil
 }
 
-func (command *DiscordCommand) setDiscordHelp(channelID string) {
-	oldHelpFunc := command.HelpFunc
-	command.HelpFunc = func(c *argparse.Command, msg interface{}) string {
-		message := oldHelpFunc(c, msg)
-		_, err := command.session.ChannelMessageSend(channelID, utils.DiscordCodeBlock(message, ""))
-		if err != nil {
-			log.Println(err)
-			return ""
+// path returns names of the command and its ancestors below the top level separated with spaces,
+// preceded by the prefix
+func (command *DiscordCommand) path(prefix string) string {
+	names := make([]string, 0)
+	for cmd := command; cmd.parent != nil; cmd = cmd.parent {
+		names = append([]string{cmd.name}, names...)
+	}
+	return strings.TrimSpace(prefix + strings.Join(names, " "))
+}
+
+// Help returns the command's usage, description, subcommands and arguments, commands preceded by the prefix
+func (command *DiscordCommand) Help(prefix string) string {
+	var b strings.Builder
+	b.WriteString("usage: " + command.usageLine(prefix) + "\n\n")
+	b.WriteString(command.description + "\n")
+
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	if len(command.commands) > 0 {
+		fmt.Fprint(w, "\nCommands:\n\n")
+		for _, cmd := range command.commands {
+			fmt.Fprintf(w, "  %s\t%s\n", cmd.name, cmd.description)
 		}
-		return ""
 	}
-	for _, cmd := range command.commands {
-		cmd.setDiscordHelp(channelID)
+	fmt.Fprint(w, "\nArguments:\n\n")
+	fmt.Fprint(w, "  -h  --help\tPrint help information\n")
+	for _, arg := range command.args {
+		short := "    "
+		if arg.sname != "" {
+			short = "-" + arg.sname + "  "
+		}
+		help := arg.opts.Help
+		if arg.opts.Default != nil {
+			help += fmt.Sprintf(". Default: %v", arg.opts.Default)
+		}
+		fmt.Fprintf(w, "  %s--%s\t%s\n", short, arg.lname, help)
 	}
+	w.Flush()
+	return b.String()
 }
 
-// Usage returns discord-formatted Usage version of github.com/akamensky/argparse.Command.Usage
-func (command *DiscordCommand) Usage(msg interface{}) string {
+// usageLine returns the command's path preceded by the prefix and followed by its arguments
+func (command *DiscordCommand) usageLine(prefix string) string {
+	usage := []string{command.path(prefix)}
+	switch {
+	case len(command.commands) > 0 && len(command.args) > 0:
+		usage = append(usage, "[<Command>]")
+	case len(command.commands) > 0:
+		usage = append(usage, "<Command>")
+	}
+	usage = append(usage, "[-h|--help]")
+	for _, arg := range command.args {
+		usage = append(usage, arg.usage())
+	}
+	return strings.Join(usage, " ")
+}
+
+// Usage returns the command's help preceded by the message, both formatted for discord
+// with commands preceded by the command prefix
+func (command *DiscordCommand) Usage(msg interface{}, commandPrefix string) string {
 	var prefix string
 	if msg != nil {
 		prefix = utils.DiscordCodeBlock(msg, "fix")
 	} else {
 		prefix = ""
 	}
-	return prefix + utils.DiscordCodeBlock(command.Command.Usage(nil), "")
+	return prefix + utils.DiscordCodeBlock(command.Help(commandPrefix), "")
 }
 
 // GetParent exposes Command's parent field
@@ -248,22 +360,6 @@ func (command *DiscordCommand) GetParent() *DiscordCommand {
 	return command.parent
 }
 
-// PrivilagesRequired indicates if privilages are required to execute this command
-// func (command *DiscordCommand) PrivilagesRequired() bool {
-// 	if command.privilagesRequired {
-// 		return true
-// 	}
-// 	if command.GetParent() != nil {
-// 		return command.GetParent().PrivilagesRequired()
-// 	}
-// 	return false
-// }
-
-// SetPrivilagesRequired sets the preivilage level for this command
-// func (command *DiscordCommand) SetPrivilagesRequired(b bool) {
-// 	command.privilagesRequired = b
-// }
-
 // IsPrivilaged checks if a given message is privilaged for this command
 func (command *DiscordCommand) IsPrivilaged(e *discordgo.MessageCreate) (bool, error) {
 	// private always privilaged
@@ -0,0 +1,187 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Context represents a single invocation of a command, it holds the values of the command's arguments
+type Context struct {
+	// Command is the invoked command
+	Command *DiscordCommand
+	// Message is the message which invoked the command
+	Message *discordgo.MessageCreate
+	// Invocation describes how the command was invoked
+	Invocation *Invocation
+	// Interaction is the slash command interaction which invoked the command, nil if it was invoked by a message
+	Interaction *discordgo.Interaction
+	// Response is the reply to a slash command seen only by its invoker, handlers may set it instead of
+	// sending messages to the channel
+	Response string
+
+	values     map[string]interface{} // maps long names of the arguments to their values
+	parsedHelp bool
+}
+
+// newContext returns a new context of the command's invocation by the message
+func newContext(command *DiscordCommand, message *discordgo.MessageCreate, inv *Invocation) *Context {
+	return &Context{
+		Command:    command,
+		Message:    message,
+		Invocation: inv,
+		values:     make(map[string]interface{}),
+	}
+}
+
+// ParsedHelp indicates if help was requested instead of executing the command
+func (ctx *Context) ParsedHelp() bool {
+	return ctx.parsedHelp
+}
+
+// Flag returns the value of the flag with the given long name
+func (ctx *Context) Flag(lname string) bool {
+	value, _ := ctx.value(lname, kindFlag).(bool)
+	return value
+}
+
+// String returns the value of the string, selector or mention argument with the given long name
+func (ctx *Context) String(lname string) string {
+	value, _ := ctx.value(lname, kindString, kindSelector, kindChannel, kindRole, kindUser).(string)
+	return value
+}
+
+// Int returns the value of the integer argument with the given long name
+func (ctx *Context) Int(lname string) int {
+	value, _ := ctx.value(lname, kindInt).(int)
+	return value
+}
+
+// StringList returns the values of the string list or words argument with the given long name
+func (ctx *Context) StringList(lname string) []string {
+	value, _ := ctx.value(lname, kindStringList, kindWords).([]string)
+	return append([]string{}, value...)
+}
+
+// value returns the value of the argument with the given long name,
+// panics if the command has no such argument of any of the given kinds as that is a programming error
+func (ctx *Context) value(lname string, kinds ...argKind) interface{} {
+	arg := ctx.Command.argByLong(lname)
+	if arg == nil {
+		panic(fmt.Sprintf("command %s has no argument %s", ctx.Command.name, lname))
+	}
+	for _, kind := range kinds {
+		if arg.kind == kind {
+			return ctx.values[lname]
+		}
+	}
+	panic(fmt.Sprintf("argument %s of command %s accessed as a wrong type", lname, ctx.Command.name))
+}
+
+// parseArguments parses the arguments of the context's command, stopping at help.
+// All tokens following -- are words, even if they look like arguments.
+func (ctx *Context) parseArguments(tokens []string) error {
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if token == "--" {
+			words := ctx.Command.wordsArg()
+			if words == nil && i+1 < len(tokens) {
+				return fmt.Errorf("unknown argument %s", tokens[i+1])
+			}
+			for _, word := range tokens[i+1:] {
+				ctx.setValue(words, word)
+			}
+			break
+		}
+		if token == "-h" || token == "--help" {
+			ctx.parsedHelp = true
+			return nil
+		}
+
+		if words := ctx.Command.wordsArg(); words != nil && !strings.HasPrefix(token, "-") {
+			ctx.setValue(words, token)
+			continue
+		}
+
+		var arg *argument
+		name, value, hasValue := token, "", false
+		if k := strings.Index(token, "="); k >= 0 {
+			name, value, hasValue = token[:k], token[k+1:], true
+		}
+		switch {
+		case strings.HasPrefix(name, "--") && len(name) > 2:
+			arg = ctx.Command.argByLong(name[2:])
+		case strings.HasPrefix(name, "-") && len(name) == 2:
+			arg = ctx.Command.argByShort(name[1:])
+		}
+		if arg == nil {
+			return fmt.Errorf("unknown argument %s", token)
+		}
+
+		if arg.kind == kindFlag {
+			if hasValue {
+				return fmt.Errorf("[%s] does not take a value", arg.name())
+			}
+			ctx.values[arg.lname] = true
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(tokens) {
+				return fmt.Errorf("[%s] must be followed by a value", arg.name())
+			}
+			i++
+			value = tokens[i]
+		}
+		err := ctx.setValue(arg, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, arg := range ctx.Command.args {
+		if _, ok := ctx.values[arg.lname]; ok {
+			continue
+		}
+		if arg.opts.Required {
+			return fmt.Errorf("[%s] is required", arg.name())
+		}
+		if arg.opts.Default != nil {
+			ctx.values[arg.lname] = arg.opts.Default
+		}
+	}
+	return nil
+}
+
+// setValue sets the value of the argument parsed from the string
+func (ctx *Context) setValue(arg *argument, value string) error {
+	switch arg.kind {
+	case kindStringList, kindWords:
+		list, _ := ctx.values[arg.lname].([]string)
+		ctx.values[arg.lname] = append(list, value)
+		return nil
+	case kindSelector:
+		valid := false
+		for _, choice := range arg.choices {
+			valid = valid || choice == value
+		}
+		if !valid {
+			return fmt.Errorf("bad value for [%s], allowed values are %s", arg.name(), strings.Join(arg.choices, ", "))
+		}
+	}
+
+	if _, ok := ctx.values[arg.lname]; ok {
+		return fmt.Errorf("[%s] can only be present once", arg.name())
+	}
+	if arg.kind == kindInt {
+		integer, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("[%s] must be followed by an integer", arg.name())
+		}
+		ctx.values[arg.lname] = integer
+		return nil
+	}
+	ctx.values[arg.lname] = value
+	return nil
+}
@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArguments(t *testing.T) {
+	parser := NewDiscordParser("!usos", "test", nil)
+	cmd := parser.NewCommand("filter", "test")
+	cmd.StringList("p", "programme", &Options{})
+	cmd.Int("i", "id", &Options{Default: -1})
+	cmd.Flag("a", "all", &Options{})
+
+	first, err := parser.parse([]string{"filter", "-p", "Mathematics", "--programme=Physics", "-a"}, nil, NewInvocation("!usos", nil, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := parser.parse([]string{"filter", "--id", "3"}, nil, NewInvocation("!usos", nil, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every parse has to produce its own values
+	if got, want := first.StringList("programme"), []string{"Mathematics", "Physics"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("StringList() = %v, want %v", got, want)
+	}
+	if !first.Flag("all") || first.Int("id") != -1 {
+		t.Errorf("first parse got all=%v id=%d", first.Flag("all"), first.Int("id"))
+	}
+	if len(second.StringList("programme")) != 0 || second.Flag("all") || second.Int("id") != 3 {
+		t.Errorf("second parse got programme=%v all=%v id=%d",
+			second.StringList("programme"), second.Flag("all"), second.Int("id"))
+	}
+}
+
+func TestParseCommandWithArgumentsAndSubcommands(t *testing.T) {
+	parser := NewDiscordParser("!usos", "test", nil)
+	cmd := parser.NewCommand("grant", "test")
+	cmd.Words("user", &Options{Required: true})
+	list := cmd.NewCommand("list", "test")
+
+	ctx, err := parser.parse([]string{"grant", "<@123>"}, nil, NewInvocation("!usos", nil, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Command != cmd || !reflect.DeepEqual(ctx.StringList("user"), []string{"<@123>"}) {
+		t.Errorf("parsed %s with user %v", ctx.Command.name, ctx.StringList("user"))
+	}
+	ctx, err = parser.parse([]string{"grant", "list"}, nil, NewInvocation("!usos", nil, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Command != list {
+		t.Errorf("parsed %s, want list", ctx.Command.name)
+	}
+}
+
+func TestParseValuesLookingLikeHelp(t *testing.T) {
+	parser := NewDiscordParser("!usos", "test", nil)
+	cmd := parser.NewCommand("grant", "test")
+	cmd.Words("user", &Options{Required: true})
+	cmd.String("r", "reason", &Options{})
+
+	ctx, err := parser.parse([]string{"grant", "--reason=-h", "--", "-h"}, nil, NewInvocation("!usos", nil, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.ParsedHelp() || ctx.String("reason") != "-h" || !reflect.DeepEqual(ctx.StringList("user"), []string{"-h"}) {
+		t.Errorf("parsed help=%v reason=%q user=%v", ctx.ParsedHelp(), ctx.String("reason"), ctx.StringList("user"))
+	}
+}
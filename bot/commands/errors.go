@@ -62,6 +62,8 @@ func NewErrHandler(err error, RedirectToCallerChannel bool) *ErrHandler {
 // ErrParse represtents failure in parsing commands
 type ErrParse struct {
 	error
+	// Usage is the error followed by the usage of the command, as reported to the invoker
+	Usage string
 }
 
 func newErrParse(err error) *ErrParse {
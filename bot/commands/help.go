@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Ogurczak/discord-usos-auth/utils"
+	"github.com/bwmarrin/discordgo"
+)
+
+// helpColor is the color of help embeds
+const helpColor = 0x5865f2
+
+// helpFilter decides which commands are shown in help to the author of a message
+type helpFilter struct {
+	scope  CommandScope
+	caller *caller
+	perms  Permissions
+}
+
+// newHelpFilter returns a filter of commands the author of the message may run in the message's scope
+func (command *DiscordCommand) newHelpFilter(e *discordgo.MessageCreate, inv *Invocation) (*helpFilter, error) {
+	scope := ScopeGuild
+	if e.GuildID == "" {
+		scope = ScopePrivate
+	}
+	c, err := command.newCaller(e)
+	if err != nil {
+		return nil, err
+	}
+	return &helpFilter{scope: scope, caller: c, perms: inv.Permissions}, nil
+}
+
+// shows checks if the command may be run, commands with subcommands are shown if any of their subcommands is
+func (filter *helpFilter) shows(command *DiscordCommand) bool {
+	if command.validateScope(filter.scope) != nil {
+		return false
+	}
+	if len(command.commands) == 0 {
+		return filter.caller.mayRun(command, filter.perms)
+	}
+	for _, cmd := range command.commands {
+		if filter.shows(cmd) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddExample adds an example of the command's invocation, given as the arguments following the command
+func (command *DiscordCommand) AddExample(example string) {
+	command.examples = append(command.examples, example)
+}
+
+// helpEmbed returns the command's help as invoked by the invocation, showing only the subcommands passing the filter
+func (command *DiscordCommand) helpEmbed(filter *helpFilter, inv *Invocation) *discordgo.MessageEmbed {
+	var description strings.Builder
+	description.WriteString(command.description + "\n" + utils.DiscordCodeSpan(command.usageLine(inv.Prefix)))
+	if aliases := inv.aliasesOf(command); len(aliases) > 0 {
+		description.WriteString("\nAliases: " + strings.Join(aliases, ", "))
+	}
+	for _, cmd := range command.commands {
+		if !filter.shows(cmd) {
+			continue
+		}
+		name := "**" + cmd.name + "**"
+		if aliases := inv.aliasesOf(cmd); len(aliases) > 0 {
+			name += " (" + strings.Join(aliases, ", ") + ")"
+		}
+		fmt.Fprintf(&description, "\n%s - %s", name, cmd.description)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       command.path(inv.Prefix),
+		Description: description.String(),
+		Color:       helpColor,
+	}
+	if len(command.args) > 0 {
+		lines := make([]string, 0, len(command.args))
+		for _, arg := range command.args {
+			line := utils.DiscordCodeSpan(arg.name())
+			if arg.opts.Help != "" {
+				line += " " + arg.opts.Help
+			}
+			if arg.opts.Default != nil {
+				line += fmt.Sprintf(" (default: %v)", arg.opts.Default)
+			}
+			lines = append(lines, line)
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Arguments",
+			Value: strings.Join(lines, "\n"),
+		})
+	}
+	if len(command.examples) > 0 {
+		lines := make([]string, 0, len(command.examples))
+		for _, example := range command.examples {
+			lines = append(lines, command.path(inv.Prefix)+" "+example)
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Examples",
+			Value: utils.DiscordCodeBlock(strings.Join(lines, "\n"), ""),
+		})
+	}
+	if len(command.commands) > 0 {
+		embed.Footer = &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Use %s <command> to see details of a command",
+				strings.TrimSpace(inv.Prefix+"help "+command.path(""))),
+		}
+	}
+	return embed
+}
+
+// sendHelp sends help of the command to the message's channel, as seen by the message's author.
+// Help of commands the author can't use is not revealed, ErrParse is returned instead.
+func (command *DiscordCommand) sendHelp(e *discordgo.MessageCreate, inv *Invocation) error {
+	filter, err := command.newHelpFilter(e, inv)
+	if err != nil {
+		return err
+	}
+	if !filter.shows(command) {
+		return newErrParse(errors.New("unknown command " + command.path("")))
+	}
+	_, err = command.session.ChannelMessageSendEmbed(e.ChannelID, command.helpEmbed(filter, inv))
+	return err
+}
+
+// newHelpCommand adds the help command to the parser
+func (parser *DiscordParser) newHelpCommand() {
+	helpCmd := parser.NewCommand("help", "Show the commands you can use here or details of the given command")
+	helpCmd.Words("command", &Options{Required: false,
+		Help: "the command to show details of"})
+	helpCmd.AddExample("filter add")
+	helpCmd.Handler = func(ctx *Context, e *discordgo.MessageCreate) *ErrHandler {
+		filter, err := parser.newHelpFilter(e, ctx.Invocation)
+		if err != nil {
+			return NewErrHandler(err, false)
+		}
+
+		cmd := parser.DiscordCommand
+		for _, name := range ctx.Invocation.expand(parser, ctx.StringList("command")) {
+			cmd = cmd.subcommand(name)
+			// commands the author can't use are not revealed
+			if cmd == nil || !filter.shows(cmd) {
+				return NewErrHandler(errors.New("unknown command "+strings.Join(ctx.StringList("command"), " ")), true)
+			}
+		}
+		_, err = parser.session.ChannelMessageSendEmbed(e.ChannelID, cmd.helpEmbed(filter, ctx.Invocation))
+		if err != nil {
+			return NewErrHandler(err, false)
+		}
+		return nil
+	}
+}
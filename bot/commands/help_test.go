@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseHelp(t *testing.T) {
+	parser := NewDiscordParser("!usos", "test", nil)
+	cmd := parser.NewCommand("filter", "test")
+	for _, tokens := range [][]string{{"-h"}, {"--help"}, {"--"}} {
+		ctx := newContext(cmd, nil, nil)
+		err := ctx.parseArguments(tokens)
+		if want := tokens[0] != "--"; ctx.ParsedHelp() != want {
+			t.Errorf("parseArguments(%q) parsed help %v, want %v (err %v)", tokens, ctx.ParsedHelp(), want, err)
+		}
+	}
+}
+
+func TestHelpEmbed(t *testing.T) {
+	parser := NewDiscordParser("!usos", "test", nil)
+	adminCmd := parser.NewCommand("filter", "manage filters")
+	adminCmd.PrivilagesRequired = true
+	adminCmd.NewCommand("add", "add a filter")
+	privateCmd := parser.NewCommand("verify", "verify yourself")
+	privateCmd.SetScope(ScopePrivate)
+	parser.NewCommand("status", "show your status")
+
+	embed := parser.helpEmbed(&helpFilter{scope: ScopeGuild, caller: &caller{privilaged: false}}, NewInvocation("!usos", nil, nil))
+	for name, shown := range map[string]bool{"help": true, "status": true, "filter": false, "verify": false} {
+		if strings.Contains(embed.Description, "**"+name+"**") != shown {
+			t.Errorf("help shows %s: %v, want %v", name, !shown, shown)
+		}
+	}
+
+	embed = parser.helpEmbed(&helpFilter{scope: ScopeGuild, caller: &caller{privilaged: true}}, NewInvocation("!usos", nil, nil))
+	if !strings.Contains(embed.Description, "**filter**") {
+		t.Error("help hides filter from privilaged users")
+	}
+}
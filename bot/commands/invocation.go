@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Invocation describes how commands are invoked in the place a message was sent to
+type Invocation struct {
+	// Prefix precedes the commands, a trailing space requires the commands to be separated from it
+	Prefix string
+	// Aliases maps aliases to space separated paths of the commands they stand for
+	Aliases map[string]string
+	// Permissions grants commands requiring privilages to roles and users
+	Permissions Permissions
+}
+
+// NewInvocation returns an invocation using the prefix, separated from the commands
+// if it ends with a letter or a digit
+func NewInvocation(prefix string, aliases map[string]string, perms Permissions) *Invocation {
+	runes := []rune(prefix)
+	if len(runes) > 0 {
+		last := runes[len(runes)-1]
+		if unicode.IsLetter(last) || unicode.IsDigit(last) {
+			prefix += " "
+		}
+	}
+	return &Invocation{Prefix: prefix, Aliases: aliases, Permissions: perms}
+}
+
+// Match returns the part of the content following the invocation's prefix
+// and whether the content starts with the prefix at all
+func (inv *Invocation) Match(content string) (string, bool) {
+	prefix := strings.TrimSpace(inv.Prefix)
+	if prefix == "" || !strings.HasPrefix(content, prefix) {
+		return "", false
+	}
+	rest := content[len(prefix):]
+	if strings.HasSuffix(inv.Prefix, " ") && rest != "" && !unicode.IsSpace([]rune(rest)[0]) {
+		return "", false
+	}
+	return rest, true
+}
+
+// expand replaces the alias starting the arguments with the path of the command it stands for,
+// names of the parser's commands take precedence over aliases
+func (inv *Invocation) expand(parser *DiscordParser, args []string) []string {
+	if len(args) == 0 || parser.subcommand(args[0]) != nil {
+		return args
+	}
+	path, ok := inv.Aliases[args[0]]
+	if !ok {
+		return args
+	}
+	return append(strings.Fields(path), args[1:]...)
+}
+
+// aliasesOf returns the sorted aliases standing for the command
+func (inv *Invocation) aliasesOf(command *DiscordCommand) []string {
+	aliases := make([]string, 0)
+	for alias, path := range inv.Aliases {
+		if strings.Join(strings.Fields(path), " ") == command.path("") {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// Lookup returns the parser's command with the given space separated path or nil if there is none
+func (parser *DiscordParser) Lookup(path string) *DiscordCommand {
+	cmd := parser.DiscordCommand
+	for _, name := range strings.Fields(path) {
+		cmd = cmd.subcommand(name)
+		if cmd == nil {
+			return nil
+		}
+	}
+	return cmd
+}
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInvocationMatch(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		content string
+		want    string
+		ok      bool
+	}{
+		{"!usos", "!usos verify -c 123", " verify -c 123", true},
+		{"!usos", "!usos", "", true},
+		{"!usos", "!usosverify", "", false},
+		{"?", "?verify", "verify", true},
+		{"?", "verify", "", false},
+	}
+	for _, test := range tests {
+		got, ok := NewInvocation(test.prefix, nil, nil).Match(test.content)
+		if got != test.want || ok != test.ok {
+			t.Errorf("Match(%q) with prefix %q = %q, %v, want %q, %v", test.content, test.prefix, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestInvocationExpand(t *testing.T) {
+	parser := NewDiscordParser("!usos", "test", nil)
+	filterCmd := parser.NewCommand("filter", "test")
+	filterCmd.NewCommand("add", "test")
+	inv := NewInvocation("!usos", map[string]string{"fa": "filter add", "help": "filter"}, nil)
+
+	if got, want := inv.expand(parser, []string{"fa", "-p", "x"}), []string{"filter", "add", "-p", "x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expand() = %v, want %v", got, want)
+	}
+	// commands take precedence over aliases
+	if got, want := inv.expand(parser, []string{"help"}), []string{"help"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expand() = %v, want %v", got, want)
+	}
+	if got, want := inv.aliasesOf(parser.Lookup("filter add")), []string{"fa"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("aliasesOf() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+)
+
+// mentionNames maps kinds of mention arguments to names of the entities they refer to
+var mentionNames = map[argKind]string{kindChannel: "channel", kindRole: "role", kindUser: "user"}
+
+// mentionPrefixes maps kinds of mention arguments to prefixes of discord mentions
+var mentionPrefixes = map[argKind]string{kindChannel: "<#", kindRole: "<@&", kindUser: "<@"}
+
+// resolveMentions replaces mentions and names in the values of the command's mention arguments with IDs
+func (ctx *Context) resolveMentions() error {
+	for _, arg := range ctx.Command.args {
+		value, _ := ctx.values[arg.lname].(string)
+		if !arg.isMention() || value == "" {
+			continue
+		}
+		id, err := ctx.resolveMention(arg.kind, value)
+		if err != nil {
+			return err
+		}
+		ctx.values[arg.lname] = id
+	}
+	return nil
+}
+
+// RoleList returns the IDs of roles given by mentions, IDs or names in the string list argument with the given long name
+func (ctx *Context) RoleList(lname string) ([]string, error) {
+	return ctx.resolveList(lname, kindRole)
+}
+
+// ChannelList returns the IDs of channels given by mentions, IDs or names in the string list argument
+// with the given long name
+func (ctx *Context) ChannelList(lname string) ([]string, error) {
+	return ctx.resolveList(lname, kindChannel)
+}
+
+// User returns the ID of the user given by a mention, ID or name as the only word of the words argument
+// with the given long name, or an empty string if no words were given
+func (ctx *Context) User(lname string) (string, error) {
+	values := ctx.StringList(lname)
+	switch len(values) {
+	case 0:
+		return "", nil
+	case 1:
+		return ctx.resolveMention(kindUser, values[0])
+	default:
+		return "", fmt.Errorf("<%s> takes a single user, quote names containing spaces", lname)
+	}
+}
+
+// resolveList resolves the values of the string list argument as mentions of the given kind
+func (ctx *Context) resolveList(lname string, kind argKind) ([]string, error) {
+	values := ctx.StringList(lname)
+	for i, value := range values {
+		id, err := ctx.resolveMention(kind, value)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = id
+	}
+	return values, nil
+}
+
+// resolveMention returns the ID of the entity the value refers to by a mention, ID or name
+func (ctx *Context) resolveMention(kind argKind, value string) (string, error) {
+	if id, ok := mentionID(kind, value); ok {
+		return id, nil
+	}
+	guildID := ctx.Message.GuildID
+	if guildID == "" {
+		return "", fmt.Errorf("%s %s must be given by a mention or ID here", mentionNames[kind], value)
+	}
+
+	session := ctx.Command.session
+	name := strings.TrimLeft(value, "#@")
+	ids := make([]string, 0)
+	switch kind {
+	case kindChannel:
+		channels, err := session.GuildChannels(guildID)
+		if err != nil {
+			return "", err
+		}
+		for _, channel := range channels {
+			if strings.EqualFold(channel.Name, name) {
+				ids = append(ids, channel.ID)
+			}
+		}
+	case kindRole:
+		roles, err := session.GuildRoles(guildID)
+		if err != nil {
+			return "", err
+		}
+		for _, role := range roles {
+			if strings.EqualFold(role.Name, name) {
+				ids = append(ids, role.ID)
+			}
+		}
+	case kindUser:
+		members, err := session.GuildMembersSearch(guildID, name, 100)
+		if err != nil {
+			return "", err
+		}
+		for _, member := range members {
+			if strings.EqualFold(member.User.Username, name) || strings.EqualFold(member.Nick, name) ||
+				strings.EqualFold(member.User.String(), name) {
+				ids = append(ids, member.User.ID)
+			}
+		}
+	}
+
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("no %s named %s", mentionNames[kind], name)
+	case 1:
+		return ids[0], nil
+	default:
+		return "", fmt.Errorf("%s name %s is ambiguous, use a mention or ID", mentionNames[kind], name)
+	}
+}
+
+// mentionID returns the ID from the mention of the given kind or the value itself if it is an ID
+func mentionID(kind argKind, value string) (string, bool) {
+	prefix := mentionPrefixes[kind]
+	if strings.HasPrefix(value, prefix) && strings.HasSuffix(value, ">") {
+		value = value[len(prefix) : len(value)-1]
+		if kind == kindUser {
+			value = strings.TrimPrefix(value, "!")
+		}
+	}
+	if value == "" {
+		return "", false
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return "", false
+		}
+	}
+	return value, true
+}
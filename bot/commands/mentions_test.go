@@ -0,0 +1,62 @@
+package commands
+
+import "testing"
+
+func TestMentionID(t *testing.T) {
+	tests := []struct {
+		kind  argKind
+		value string
+		id    string
+		ok    bool
+	}{
+		{kindChannel, "<#123>", "123", true},
+		{kindRole, "<@&123>", "123", true},
+		{kindUser, "<@123>", "123", true},
+		{kindUser, "<@!123>", "123", true},
+		{kindUser, "123", "123", true},
+		{kindUser, "<@&123>", "", false},
+		{kindChannel, "general", "", false},
+		{kindRole, "<@&>", "", false},
+	}
+	for _, test := range tests {
+		id, ok := mentionID(test.kind, test.value)
+		if id != test.id || ok != test.ok {
+			t.Errorf("mentionID(%d, %q) = %q, %v, want %q, %v", test.kind, test.value, id, ok, test.id, test.ok)
+		}
+	}
+}
+
+func TestRoleList(t *testing.T) {
+	parser := NewDiscordParser("!usos", "test", nil)
+	cmd := parser.NewCommand("unverified", "test")
+	cmd.StringList("x", "exempt", &Options{Required: false})
+	ctx := newContext(cmd, nil, nil)
+	ctx.values["exempt"] = []string{"<@&123>", "456"}
+
+	ids, err := ctx.RoleList("exempt")
+	if err != nil || len(ids) != 2 || ids[0] != "123" || ids[1] != "456" {
+		t.Errorf("RoleList() = %q, %v, want [123 456]", ids, err)
+	}
+	if ctx.StringList("exempt")[0] != "<@&123>" {
+		t.Error("RoleList() modified the argument's values")
+	}
+}
+
+func TestUser(t *testing.T) {
+	parser := NewDiscordParser("!usos", "test", nil)
+	cmd := parser.NewCommand("deauth", "test")
+	cmd.Words("user", &Options{Required: false})
+	ctx := newContext(cmd, nil, nil)
+
+	if id, err := ctx.User("user"); id != "" || err != nil {
+		t.Errorf("User() = %q, %v, want no user", id, err)
+	}
+	ctx.values["user"] = []string{"<@!123>"}
+	if id, err := ctx.User("user"); id != "123" || err != nil {
+		t.Errorf("User() = %q, %v, want 123", id, err)
+	}
+	ctx.values["user"] = []string{"<@123>", "<@456>"}
+	if _, err := ctx.User("user"); err == nil {
+		t.Error("User() accepted two users")
+	}
+}
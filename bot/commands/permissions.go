@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// PermissionGrant allows roles and users to run a command and its subcommands despite them requiring privilages
+type PermissionGrant struct {
+	RoleIDs map[string]bool
+	UserIDs map[string]bool
+}
+
+// Permissions maps space separated paths of commands to their grants
+type Permissions map[string]*PermissionGrant
+
+// Grant allows the role or the user, whichever is not empty, to run the command with the given path
+func (perms Permissions) Grant(path string, roleID string, userID string) {
+	path = strings.Join(strings.Fields(path), " ")
+	grant := perms[path]
+	if grant == nil {
+		grant = &PermissionGrant{RoleIDs: make(map[string]bool), UserIDs: make(map[string]bool)}
+		perms[path] = grant
+	}
+	if roleID != "" {
+		grant.RoleIDs[roleID] = true
+	}
+	if userID != "" {
+		grant.UserIDs[userID] = true
+	}
+}
+
+// Revoke takes back the permission to run the command with the given path from the role or the user,
+// returns false if it was not granted
+func (perms Permissions) Revoke(path string, roleID string, userID string) bool {
+	path = strings.Join(strings.Fields(path), " ")
+	grant := perms[path]
+	if grant == nil || roleID != "" && !grant.RoleIDs[roleID] || userID != "" && !grant.UserIDs[userID] {
+		return false
+	}
+	delete(grant.RoleIDs, roleID)
+	delete(grant.UserIDs, userID)
+	if len(grant.RoleIDs) == 0 && len(grant.UserIDs) == 0 {
+		delete(perms, path)
+	}
+	return true
+}
+
+// RemoveRole takes back all permissions granted to the role
+func (perms Permissions) RemoveRole(roleID string) {
+	for path := range perms {
+		perms.Revoke(path, roleID, "")
+	}
+}
+
+// RemoveUser takes back all permissions granted to the user
+func (perms Permissions) RemoveUser(userID string) {
+	for path := range perms {
+		perms.Revoke(path, "", userID)
+	}
+}
+
+// Copy returns a deep copy of the permissions
+func (perms Permissions) Copy() Permissions {
+	permsCopy := make(Permissions, len(perms))
+	for path, grant := range perms {
+		for roleID := range grant.RoleIDs {
+			permsCopy.Grant(path, roleID, "")
+		}
+		for userID := range grant.UserIDs {
+			permsCopy.Grant(path, "", userID)
+		}
+	}
+	return permsCopy
+}
+
+// allows checks if the user or any of the roles was granted the command or any of its ancestors
+func (perms Permissions) allows(command *DiscordCommand, userID string, roleIDs []string) bool {
+	for cmd := command; cmd.parent != nil; cmd = cmd.parent {
+		grant := perms[cmd.path("")]
+		if grant == nil {
+			continue
+		}
+		if grant.UserIDs[userID] {
+			return true
+		}
+		for _, roleID := range roleIDs {
+			if grant.RoleIDs[roleID] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// caller represents the author of a message as seen by permission checks
+type caller struct {
+	privilaged bool // is the guild's owner or an administrator
+	userID     string
+	roleIDs    []string
+}
+
+// newCaller returns the author of the message
+func (command *DiscordCommand) newCaller(e *discordgo.MessageCreate) (*caller, error) {
+	privilaged, err := command.IsPrivilaged(e)
+	if err != nil {
+		return nil, err
+	}
+	c := &caller{privilaged: privilaged, userID: e.Author.ID}
+	if privilaged {
+		return c, nil
+	}
+
+	member := e.Member
+	if member == nil {
+		member, err = command.session.GuildMember(e.GuildID, e.Author.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	c.roleIDs = member.Roles
+	return c, nil
+}
+
+// mayRun checks if the caller may run the command, privilaged callers may run all commands,
+// others need the permissions to grant them commands requiring privilages
+func (c *caller) mayRun(command *DiscordCommand, perms Permissions) bool {
+	return !command.PrivilagesRequired || c.privilaged || perms.allows(command, c.userID, c.roleIDs)
+}
@@ -0,0 +1,38 @@
+package commands
+
+import "testing"
+
+func TestPermissions(t *testing.T) {
+	parser := NewDiscordParser("!usos", "test", nil)
+	filterCmd := parser.NewCommand("filter", "test")
+	filterCmd.PrivilagesRequired = true
+	addCmd := filterCmd.NewCommand("add", "test")
+	roleCmd := parser.NewCommand("role", "test")
+	roleCmd.PrivilagesRequired = true
+
+	perms := make(Permissions)
+	perms.Grant("filter", "moderatorsID", "")
+	perms.Grant("role", "", "userID")
+
+	moderator := &caller{userID: "otherID", roleIDs: []string{"moderatorsID"}}
+	if !moderator.mayRun(addCmd, perms) {
+		t.Error("granting a command group does not grant its subcommands")
+	}
+	if moderator.mayRun(roleCmd, perms) {
+		t.Error("role granted to the moderators")
+	}
+	if !(&caller{userID: "userID"}).mayRun(roleCmd, perms) {
+		t.Error("role not granted to the user")
+	}
+
+	if !perms.Revoke("filter", "moderatorsID", "") || moderator.mayRun(addCmd, perms) {
+		t.Error("filter not revoked from the moderators")
+	}
+	if perms.Revoke("filter", "moderatorsID", "") {
+		t.Error("filter revoked twice")
+	}
+	perms.RemoveUser("userID")
+	if len(perms) != 0 {
+		t.Errorf("empty grants left: %v", perms)
+	}
+}
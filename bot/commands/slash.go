@@ -0,0 +1,191 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxSlashDescriptionLen is the maximal length of slash commands' and their options' descriptions
+const maxSlashDescriptionLen = 100
+
+// slashOptionTypes maps kinds of arguments to types of slash command options
+var slashOptionTypes = map[argKind]discordgo.ApplicationCommandOptionType{
+	kindFlag:       discordgo.ApplicationCommandOptionBoolean,
+	kindString:     discordgo.ApplicationCommandOptionString,
+	kindInt:        discordgo.ApplicationCommandOptionInteger,
+	kindStringList: discordgo.ApplicationCommandOptionString,
+	kindSelector:   discordgo.ApplicationCommandOptionString,
+	kindChannel:    discordgo.ApplicationCommandOptionChannel,
+	kindRole:       discordgo.ApplicationCommandOptionRole,
+	kindUser:       discordgo.ApplicationCommandOptionUser,
+	kindWords:      discordgo.ApplicationCommandOptionString,
+}
+
+// ApplicationCommands returns slash commands corresponding to the parser's commands
+func (parser *DiscordParser) ApplicationCommands() ([]*discordgo.ApplicationCommand, error) {
+	appCommands := make([]*discordgo.ApplicationCommand, 0, len(parser.commands))
+	for _, cmd := range parser.commands {
+		options, err := cmd.slashOptions(0)
+		if err != nil {
+			return nil, err
+		}
+		appCommands = append(appCommands, &discordgo.ApplicationCommand{
+			Type:        discordgo.ChatApplicationCommand,
+			Name:        cmd.name,
+			Description: slashDescription(cmd.description),
+			Options:     options,
+		})
+	}
+	return appCommands, nil
+}
+
+// slashOptions returns the command's subcommands or, if it has none, its arguments as slash command options.
+// Arguments of a command with subcommands are taken by the subcommand named after its SlashName.
+// Depth is the number of the command's ancestors below the top level command.
+func (command *DiscordCommand) slashOptions(depth int) ([]*discordgo.ApplicationCommandOption, error) {
+	if len(command.commands) == 0 {
+		return command.slashArguments(), nil
+	}
+	if depth > 1 {
+		return nil, fmt.Errorf("command %s is nested too deep for a slash command", command.name)
+	}
+	options := make([]*discordgo.ApplicationCommandOption, 0, len(command.commands)+1)
+	if len(command.args) > 0 {
+		if command.SlashName == "" || command.subcommand(command.SlashName) != nil {
+			return nil, fmt.Errorf("command %s has both subcommands and arguments without a free slash name", command.name)
+		}
+		options = append(options, &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        command.SlashName,
+			Description: slashDescription(command.description),
+			Options:     command.slashArguments(),
+		})
+	}
+	for _, cmd := range command.commands {
+		subOptions, err := cmd.slashOptions(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		optionType := discordgo.ApplicationCommandOptionSubCommand
+		if len(cmd.commands) > 0 {
+			optionType = discordgo.ApplicationCommandOptionSubCommandGroup
+		}
+		options = append(options, &discordgo.ApplicationCommandOption{
+			Type:        optionType,
+			Name:        cmd.name,
+			Description: slashDescription(cmd.description),
+			Options:     subOptions,
+		})
+	}
+	return options, nil
+}
+
+// slashArguments returns the command's arguments as slash command options, required ones first
+func (command *DiscordCommand) slashArguments() []*discordgo.ApplicationCommandOption {
+	required := make([]*discordgo.ApplicationCommandOption, 0)
+	optional := make([]*discordgo.ApplicationCommandOption, 0)
+	for _, arg := range command.args {
+		option := &discordgo.ApplicationCommandOption{
+			Type:        slashOptionTypes[arg.kind],
+			Name:        arg.lname,
+			Description: slashDescription(arg.opts.Help),
+			Required:    arg.opts.Required,
+		}
+		for _, choice := range arg.choices {
+			option.Choices = append(option.Choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  choice,
+				Value: choice,
+			})
+		}
+
+		if option.Required {
+			required = append(required, option)
+		} else {
+			optional = append(optional, option)
+		}
+	}
+	return append(required, optional...)
+}
+
+// slashDescription adjusts the description to the limits of slash commands
+func slashDescription(description string) string {
+	if description == "" {
+		return "-"
+	}
+	if len(description) > maxSlashDescriptionLen {
+		return description[:maxSlashDescriptionLen-3] + "..."
+	}
+	return description
+}
+
+// InteractionArgs translates the slash command interaction into arguments of the parser's commands,
+// values of list options are tokenized like messages so they can be quoted.
+// Values are never taken for arguments or help, they are attached to their options' names and words follow --.
+func (parser *DiscordParser) InteractionArgs(data discordgo.ApplicationCommandInteractionData) ([]string, error) {
+	return parser.appendInteractionArgs(make([]string, 0), []*discordgo.ApplicationCommandInteractionDataOption{{
+		Name:    data.Name,
+		Type:    discordgo.ApplicationCommandOptionSubCommand,
+		Options: data.Options,
+	}})
+}
+
+// appendInteractionArgs appends arguments corresponding to the command's slash command options to args
+func (command *DiscordCommand) appendInteractionArgs(args []string,
+	options []*discordgo.ApplicationCommandInteractionDataOption) ([]string, error) {
+	var words []string
+	for _, option := range options {
+		switch option.Type {
+		case discordgo.ApplicationCommandOptionSubCommand, discordgo.ApplicationCommandOptionSubCommandGroup:
+			if option.Name == command.SlashName && command.subcommand(option.Name) == nil {
+				var err error
+				args, err = command.appendInteractionArgs(args, option.Options)
+				if err != nil {
+					return nil, err
+				}
+				break
+			}
+			args = append(args, option.Name)
+			if cmd := command.subcommand(option.Name); cmd != nil {
+				var err error
+				args, err = cmd.appendInteractionArgs(args, option.Options)
+				if err != nil {
+					return nil, err
+				}
+			}
+		case discordgo.ApplicationCommandOptionBoolean:
+			if option.BoolValue() {
+				args = append(args, "--"+option.Name)
+			}
+		case discordgo.ApplicationCommandOptionInteger:
+			args = append(args, "--"+option.Name+"="+strconv.FormatInt(option.IntValue(), 10))
+		case discordgo.ApplicationCommandOptionString:
+			arg := command.argByLong(option.Name)
+			if arg == nil || arg.kind != kindWords && arg.kind != kindStringList {
+				args = append(args, "--"+option.Name+"="+option.StringValue())
+				break
+			}
+			values, err := Tokenize(option.StringValue())
+			if err != nil {
+				return nil, err
+			}
+			if arg.kind == kindWords {
+				words = append(words, values...)
+				break
+			}
+			// list arguments take one value per occurrence
+			for _, value := range values {
+				args = append(args, "--"+option.Name+"="+value)
+			}
+		default:
+			// user, role, channel and mentionable options hold IDs
+			args = append(args, "--"+option.Name+"="+fmt.Sprint(option.Value))
+		}
+	}
+	if len(words) > 0 {
+		args = append(args, "--")
+		args = append(args, words...)
+	}
+	return args, nil
+}
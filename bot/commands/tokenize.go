@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// Tokenize splits the command into arguments like a shell does.
+// Arguments are separated by whitespace, unless it is quoted or escaped with a backslash.
+// Inside single quotes all characters are literal, inside double quotes only \" and \\ are escapes.
+func Tokenize(command string) ([]string, error) {
+	args := make([]string, 0)
+	var current strings.Builder
+	inArg := false // distinguishes an empty quoted argument from no argument
+	var quote rune
+	escaped := false
+
+	for _, r := range command {
+		switch {
+		case escaped:
+			if quote == '"' && r != '"' && r != '\\' {
+				current.WriteRune('\\')
+			}
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if escaped {
+		return nil, errors.New("command ends with an unfinished escape")
+	}
+	if quote != 0 {
+		return nil, errors.New("command has an unterminated quote")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{`!usos role -i 123`, []string{"!usos", "role", "-i", "123"}},
+		{`!usos auth-msg -m "React here to verify"`, []string{"!usos", "auth-msg", "-m", "React here to verify"}},
+		{`-p 'Computer Science' -p Mathematics`, []string{"-p", "Computer Science", "-p", "Mathematics"}},
+		{`-m "say \"hi\" \n" x`, []string{"-m", `say "hi" \n`, "x"}},
+		{`-m 'no \escapes'`, []string{"-m", `no \escapes`}},
+		{`a\ b c`, []string{"a b", "c"}},
+		{`-r "" x`, []string{"-r", "", "x"}},
+		{`pre"quoted part"post`, []string{"prequoted partpost"}},
+		{"  \t ", []string{}},
+	}
+	for _, test := range tests {
+		got, err := Tokenize(test.command)
+		if err != nil {
+			t.Errorf("Tokenize(%q) returned error %v", test.command, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", test.command, got, test.want)
+		}
+	}
+}
+
+func TestTokenizeErrors(t *testing.T) {
+	for _, command := range []string{`-m "unterminated`, `-m 'unterminated`, `trailing\`} {
+		if _, err := Tokenize(command); err == nil {
+			t.Errorf("Tokenize(%q) should fail", command)
+		}
+	}
+}
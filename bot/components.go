@@ -0,0 +1,211 @@
+package bot
+
+import (
+	"log"
+	"strings"
+
+	"github.com/Ogurczak/discord-usos-auth/usos"
+	"github.com/bwmarrin/discordgo"
+)
+
+// custom IDs of the bot's message components and modals
+const (
+	verifyButtonID = "usos-verify"
+	codeButtonID   = "usos-code"
+	codeModalID    = "usos-code-modal"
+	codeInputID    = "usos-code-verifier"
+)
+
+// verifyButton returns components with a button starting authorization
+func verifyButton() []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    "Verify with USOS",
+				Style:    discordgo.PrimaryButton,
+				CustomID: verifyButtonID,
+			},
+		}},
+	}
+}
+
+// enterCodeButton returns a button opening a modal for entering the verifier
+func enterCodeButton() discordgo.Button {
+	return discordgo.Button{
+		Label:    "Enter code",
+		Style:    discordgo.PrimaryButton,
+		CustomID: codeButtonID,
+	}
+}
+
+// handleComponent handles clicks on the bot's message components
+func (bot *UsosBot) handleComponent(e *discordgo.InteractionCreate) {
+	var err error
+	switch e.MessageComponentData().CustomID {
+	case verifyButtonID:
+		err = bot.handleVerifyButton(e)
+	case codeButtonID:
+		err = bot.handleCodeButton(e)
+	default:
+		return
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// handleVerifyButton starts authorization of the member who clicked the verify button
+// and replies with the authorization link and a button for entering the verifier
+func (bot *UsosBot) handleVerifyButton(e *discordgo.InteractionCreate) error {
+	if e.Member == nil {
+		return nil
+	}
+	err := bot.deferEphemeral(e)
+	if err != nil {
+		return err
+	}
+
+	member := e.Member
+	member.GuildID = e.GuildID
+	reply, components, err := bot.verifyButtonReply(member)
+	if err != nil {
+		log.Println(err)
+		reply, components = "Something went wrong, try again later", nil
+	}
+	_, err = bot.InteractionResponseEdit(e.Interaction, &discordgo.WebhookEdit{
+		Content:    reply,
+		Components: components,
+	})
+	return err
+}
+
+// verifyButtonReply authorizes the member with his linked identity if the guild allows it,
+// otherwise registers him for authorization and returns a reply with the authorization link
+func (bot *UsosBot) verifyButtonReply(member *discordgo.Member) (string, []discordgo.MessageComponent, error) {
+	authorized, err := bot.isAuthorized(member)
+	if err != nil {
+		return "", nil, err
+	}
+	if authorized {
+		return "You are already verified on this server", nil, nil
+	}
+
+	bot.mu.Lock()
+	reuse := bot.canReuseIdentity(member.GuildID, member.User.ID)
+	bot.mu.Unlock()
+	if reuse {
+		err = bot.authorizeWithIdentity(member)
+		switch err.(type) {
+		case nil:
+			return bot.authorizationSummary(map[string]error{member.GuildID: nil}), nil, nil
+		case *ErrFilteredOut, *ErrUsosAccountInUse, *ErrGraduated:
+			return err.Error(), nil, nil
+		case *ErrIdentityStale:
+			// fall back to regular authorization
+		default:
+			return "", nil, err
+		}
+	}
+
+	authorizationURL, err := bot.registerUnauthorizedMember(member)
+	if _, ok := err.(*ErrAlreadyRegistered); ok {
+		authorizationURL, err = bot.renewRequestToken(member.User.ID, member.GuildID)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	reply := "Log in to USOS using the link below and authorize the bot. " +
+		"Then click the other button and enter the verification code you were given."
+	components := []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label: "Log in to USOS",
+				Style: discordgo.LinkButton,
+				URL:   authorizationURL.String(),
+			},
+			enterCodeButton(),
+		}},
+	}
+	return reply, components, nil
+}
+
+// handleCodeButton opens a modal for entering the verifier
+func (bot *UsosBot) handleCodeButton(e *discordgo.InteractionCreate) error {
+	return bot.InteractionRespond(e.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: codeModalID,
+			Title:    "USOS verification",
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+					discordgo.TextInput{
+						CustomID: codeInputID,
+						Label:    "Verification code",
+						Style:    discordgo.TextInputShort,
+						Required: true,
+					},
+				}},
+			},
+		},
+	})
+}
+
+// handleModalSubmit finalizes authorization of the user with the verifier entered in the modal
+func (bot *UsosBot) handleModalSubmit(e *discordgo.InteractionCreate) {
+	data := e.ModalSubmitData()
+	if data.CustomID != codeModalID {
+		return
+	}
+	err := bot.deferEphemeral(e)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	user := e.User
+	if e.Member != nil {
+		user = e.Member.User
+	}
+	verifier := strings.TrimSpace(modalValue(data, codeInputID))
+
+	var reply string
+	results, err := bot.finalizeAuthorization(user, verifier)
+	switch err.(type) {
+	case nil:
+		reply = bot.authorizationSummary(results)
+	case *ErrUnregisteredUnauthorizedUser, *usos.ErrUnableToCall, *ErrWrongVerifier:
+		reply = err.Error()
+	default:
+		log.Println(err)
+		reply = "Something went wrong, try again later"
+	}
+	_, err = bot.InteractionResponseEdit(e.Interaction, &discordgo.WebhookEdit{Content: reply})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// modalValue returns the value of the modal's text input with the given custom ID
+func modalValue(data discordgo.ModalSubmitInteractionData, customID string) string {
+	for _, row := range data.Components {
+		actionsRow, ok := row.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, component := range actionsRow.Components {
+			if input, ok := component.(*discordgo.TextInput); ok && input.CustomID == customID {
+				return input.Value
+			}
+		}
+	}
+	return ""
+}
+
+// deferEphemeral acknowledges the interaction, promising an ephemeral reply
+func (bot *UsosBot) deferEphemeral(e *discordgo.InteractionCreate) error {
+	return bot.InteractionRespond(e.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: uint64(discordgo.MessageFlagsEphemeral)},
+	})
+}
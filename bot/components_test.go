@@ -0,0 +1,25 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestModalValue(t *testing.T) {
+	data := discordgo.ModalSubmitInteractionData{
+		CustomID: codeModalID,
+		Components: []discordgo.MessageComponent{
+			&discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				&discordgo.TextInput{CustomID: "other", Value: "other value"},
+				&discordgo.TextInput{CustomID: codeInputID, Value: "verifier"},
+			}},
+		},
+	}
+	if got := modalValue(data, codeInputID); got != "verifier" {
+		t.Errorf("modalValue() = %q, want %q", got, "verifier")
+	}
+	if got := modalValue(data, "missing"); got != "" {
+		t.Errorf("modalValue() = %q, want empty", got)
+	}
+}
@@ -0,0 +1,282 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Ogurczak/discord-usos-auth/usos"
+	"github.com/bwmarrin/discordgo"
+)
+
+// courseProvisioning represents a guild's settings of automatic course roles and channels
+type courseProvisioning struct {
+	Enabled           bool
+	CategoryID        string                        // category to create course channels in
+	ArchiveCategoryID string                        // category to move channels of ended courses to, optional
+	MinMembers        int                           // courses with that many verified members are provisioned, 0 disables
+	Approved          map[string]bool               // course IDs provisioned regardless of their member count
+	Courses           map[string]*provisionedCourse // maps course id to its role and channel
+}
+
+// provisionedCourse represents a course which got its own role and channel
+type provisionedCourse struct {
+	Name      string
+	TermID    string
+	TermEnd   time.Time
+	RoleID    string
+	ChannelID string
+	Archived  bool
+}
+
+// initialize makes sure the provisioning's collections are allocated
+func (provisioning *courseProvisioning) initialize() {
+	if provisioning.Approved == nil {
+		provisioning.Approved = make(map[string]bool)
+	}
+	if provisioning.Courses == nil {
+		provisioning.Courses = make(map[string]*provisionedCourse)
+	}
+}
+
+// courseAttendees maps ids of courses attended by the guild's verified members to the members' ids,
+// courses are known only of members who linked their usos identities
+func (bot *UsosBot) courseAttendees(guildID string) (map[string][]string, map[string]*provisionedCourse) {
+	attendees := make(map[string][]string)
+	courses := make(map[string]*provisionedCourse)
+	for userID := range bot.getGuildUsosInfo(guildID).VerifiedMembers {
+		if usosUser := bot.linkedUser(userID); usosUser != nil {
+			addCourseAttendee(attendees, courses, userID, usosUser)
+		}
+	}
+	return attendees, courses
+}
+
+// addCourseAttendee adds the courses attended by the usos user to courses and the user's id to their attendees
+func addCourseAttendee(attendees map[string][]string, courses map[string]*provisionedCourse,
+	userID string, usosUser *usos.User) {
+	for _, course := range usosUser.Courses {
+		attendees[course.ID] = append(attendees[course.ID], userID)
+		if courses[course.ID] == nil {
+			courses[course.ID] = &provisionedCourse{Name: course.Name, TermID: course.TermID}
+			if term := usosUser.Term(course.TermID); term != nil {
+				courses[course.ID].TermEnd = term.EndDate
+			}
+		}
+	}
+}
+
+// provisionCourses creates roles and channels for courses qualifying for them
+// and gives the roles to all of their verified attendees. The usos data of the member being verified,
+// if any, counts even if he has not linked his usos identity.
+func (bot *UsosBot) provisionCourses(guildID string, userID string, usosUser *usos.User) error {
+	// the courses are created without holding the state lock, only one provisioning may create them at a time
+	bot.provisionMu.Lock()
+	defer bot.provisionMu.Unlock()
+
+	bot.mu.Lock()
+	provisioning := &bot.getGuildUsosInfo(guildID).CourseProvisioning
+	if !provisioning.Enabled {
+		bot.mu.Unlock()
+		return nil
+	}
+	categoryID := provisioning.CategoryID
+	attendees, courses := bot.courseAttendees(guildID)
+	if usosUser != nil && bot.linkedUser(userID) == nil {
+		addCourseAttendee(attendees, courses, userID, usosUser)
+	}
+	for courseID, course := range courses {
+		qualifies := provisioning.Approved[courseID] ||
+			provisioning.MinMembers > 0 && len(attendees[courseID]) >= provisioning.MinMembers
+		if provisioning.Courses[courseID] != nil || !qualifies ||
+			!course.TermEnd.IsZero() && time.Now().After(course.TermEnd) {
+			delete(courses, courseID)
+		}
+	}
+	bot.mu.Unlock()
+
+	for courseID, course := range courses {
+		err := bot.createCourseRoleAndChannel(guildID, categoryID, courseID, course)
+		if err != nil {
+			return err
+		}
+		bot.mu.Lock()
+		bot.getGuildUsosInfo(guildID).CourseProvisioning.Courses[courseID] = course
+		bot.mu.Unlock()
+		for _, userID := range attendees[courseID] {
+			err = bot.GuildMemberRoleAdd(guildID, userID, course.RoleID)
+			if err != nil && !IsNotFound(err) {
+				return err
+			}
+		}
+
+		err = bot.logDiscord(guildID, fmt.Sprintf("Provisioned course %s with role <@&%s> and channel <#%s>",
+			courseID, course.RoleID, course.ChannelID))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createCourseRoleAndChannel creates a role and a channel visible only to that role for the given course
+func (bot *UsosBot) createCourseRoleAndChannel(guildID string, categoryID string, courseID string,
+	course *provisionedCourse) error {
+	role, err := bot.GuildRoleCreate(guildID)
+	if err != nil {
+		return err
+	}
+	// a role left behind would be created again on every attempt
+	deleteRole := func(cause error) error {
+		err := bot.GuildRoleDelete(guildID, role.ID)
+		if err != nil {
+			log.Println(err)
+		}
+		return cause
+	}
+	role, err = bot.GuildRoleEdit(guildID, role.ID, course.Name, 0, false, 0, false)
+	if err != nil {
+		return deleteRole(err)
+	}
+
+	channel, err := bot.GuildChannelCreateComplex(guildID, discordgo.GuildChannelCreateData{
+		Name:     course.Name,
+		Type:     discordgo.ChannelTypeGuildText,
+		Topic:    courseID,
+		ParentID: categoryID,
+		PermissionOverwrites: []*discordgo.PermissionOverwrite{
+			{ID: guildID, Type: discordgo.PermissionOverwriteTypeRole, Deny: discordgo.PermissionViewChannel},
+			{ID: role.ID, Type: discordgo.PermissionOverwriteTypeRole, Allow: discordgo.PermissionViewChannel},
+			{ID: bot.State.User.ID, Type: discordgo.PermissionOverwriteTypeMember, Allow: discordgo.PermissionViewChannel},
+		},
+	})
+	if err != nil {
+		return deleteRole(err)
+	}
+	course.RoleID = role.ID
+	course.ChannelID = channel.ID
+	return nil
+}
+
+// provisionCoursesOrReport provisions the guild's courses and gives the member roles of courses he attends,
+// failures are reported to the log channels as the member's verification has already succeeded
+func (bot *UsosBot) provisionCoursesOrReport(guildID string, userID string, usosUser *usos.User) {
+	err := bot.provisionCourses(guildID, userID, usosUser)
+	if err == nil {
+		err = bot.assignCourseRoles(guildID, userID, usosUser)
+	}
+	if err == nil {
+		return
+	}
+	log.Println(err)
+	err = bot.logDiscord(guildID, fmt.Sprintf("Could not provision courses of <@%s>: %v", userID, err))
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// assignCourseRoles gives the member roles of provisioned courses he attends
+func (bot *UsosBot) assignCourseRoles(guildID string, userID string, usosUser *usos.User) error {
+	if usosUser == nil {
+		return nil
+	}
+	bot.mu.Lock()
+	provisioning := &bot.getGuildUsosInfo(guildID).CourseProvisioning
+	roleIDs := make([]string, 0)
+	for _, course := range usosUser.Courses {
+		provisioned := provisioning.Courses[course.ID]
+		if !provisioning.Enabled || provisioned == nil || provisioned.Archived {
+			continue
+		}
+		roleIDs = append(roleIDs, provisioned.RoleID)
+	}
+	bot.mu.Unlock()
+
+	for _, roleID := range roleIDs {
+		err := bot.GuildMemberRoleAdd(guildID, userID, roleID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveEndedCourses makes channels of courses whose term has ended read only
+// and moves them to the archive category if there is one
+func (bot *UsosBot) archiveEndedCourses(guildID string) error {
+	bot.mu.Lock()
+	provisioning := &bot.getGuildUsosInfo(guildID).CourseProvisioning
+	archiveCategoryID := provisioning.ArchiveCategoryID
+	ended := make(map[string]provisionedCourse)
+	for courseID, course := range provisioning.Courses {
+		if course.Archived || course.TermEnd.IsZero() || time.Now().Before(course.TermEnd) {
+			continue
+		}
+		ended[courseID] = *course
+	}
+	bot.mu.Unlock()
+
+	for courseID, course := range ended {
+		err := bot.ChannelPermissionSet(course.ChannelID, course.RoleID, discordgo.PermissionOverwriteTypeRole,
+			discordgo.PermissionViewChannel, discordgo.PermissionSendMessages)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+		if archiveCategoryID != "" {
+			err = bot.moveChannel(course.ChannelID, archiveCategoryID)
+			if err != nil && !IsNotFound(err) {
+				return err
+			}
+		}
+		bot.mu.Lock()
+		if provisioned := bot.getGuildUsosInfo(guildID).CourseProvisioning.Courses[courseID]; provisioned != nil {
+			provisioned.Archived = true
+		}
+		bot.mu.Unlock()
+
+		err = bot.logDiscord(guildID, fmt.Sprintf("Archived channel <#%s> of course %s, its term has ended",
+			course.ChannelID, courseID))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// guildCategory returns the guild's category channel with the given ID
+func (bot *UsosBot) guildCategory(guildID string, categoryID string) (*discordgo.Channel, error) {
+	channel, err := bot.Channel(categoryID)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, newErrCategoryNotFound(categoryID, guildID)
+		}
+		return nil, err
+	}
+	if channel.GuildID != guildID || channel.Type != discordgo.ChannelTypeGuildCategory {
+		return nil, newErrCategoryNotFound(categoryID, guildID)
+	}
+	return channel, nil
+}
+
+// moveChannel moves the channel to the given category keeping its position
+func (bot *UsosBot) moveChannel(channelID string, categoryID string) error {
+	channel, err := bot.Channel(channelID)
+	if err != nil {
+		return err
+	}
+	_, err = bot.ChannelEditComplex(channelID, &discordgo.ChannelEdit{
+		ParentID: categoryID,
+		Position: channel.Position,
+	})
+	return err
+}
+
+// forgetProvisionedResource marks a course whose role or channel was deleted as archived,
+// so that it is not provisioned again
+func (bot *UsosBot) forgetProvisionedResource(guildID string, resourceID string) {
+	for _, course := range bot.getGuildUsosInfo(guildID).CourseProvisioning.Courses {
+		if course.RoleID == resourceID || course.ChannelID == resourceID {
+			course.Archived = true
+		}
+	}
+}
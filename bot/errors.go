@@ -47,17 +47,15 @@ func (e *ErrFilteredOut) Error() string {
 // ErrWrongVerifier represtents failure in verifying the user with usos caused by wrong verifier
 type ErrWrongVerifier struct {
 	error
-	UserID                string
-	RequestTokenGuildPair *requestTokenGuildPair
-	verifier              string
+	UserID   string
+	verifier string
 }
 
-func newErrWrongVerifier(cause error, UserID string, RequestTokenGuildPair *requestTokenGuildPair, verifier string) *ErrWrongVerifier {
+func newErrWrongVerifier(cause error, UserID string, verifier string) *ErrWrongVerifier {
 	return &ErrWrongVerifier{
-		error:                 cause,
-		UserID:                UserID,
-		RequestTokenGuildPair: RequestTokenGuildPair,
-		verifier:              verifier,
+		error:    cause,
+		UserID:   UserID,
+		verifier: verifier,
 	}
 }
 func (e *ErrWrongVerifier) Error() string {
@@ -184,10 +182,276 @@ func (e *ErrFilterNotFound) Error() string {
 	return "No filter with such ID specified."
 }
 
+// ErrIdentityNotLinked represtents failure in using an usos identity the user did not link
+type ErrIdentityNotLinked struct {
+	UserID string
+}
+
+func newErrIdentityNotLinked(UserID string) *ErrIdentityNotLinked {
+	return &ErrIdentityNotLinked{
+		UserID: UserID,
+	}
+}
+func (e *ErrIdentityNotLinked) Error() string {
+	return "You do not have a linked usos identity."
+}
+
+// ErrIdentityStale represtents failure in refreshing a linked usos identity
+type ErrIdentityStale struct {
+	error
+	UserID string
+}
+
+func newErrIdentityStale(cause error, UserID string) *ErrIdentityStale {
+	return &ErrIdentityStale{
+		error:  cause,
+		UserID: UserID,
+	}
+}
+func (e *ErrIdentityStale) Error() string {
+	return "Your linked usos identity could not be refreshed."
+}
+
+// ErrUsosAccountInUse represtents failure in verifying with an usos account already verifying another discord account
+type ErrUsosAccountInUse struct {
+	UserID      string
+	OtherUserID string
+}
+
+func newErrUsosAccountInUse(UserID string, OtherUserID string) *ErrUsosAccountInUse {
+	return &ErrUsosAccountInUse{
+		UserID:      UserID,
+		OtherUserID: OtherUserID,
+	}
+}
+func (e *ErrUsosAccountInUse) Error() string {
+	return "This usos account already verifies another discord account on this server."
+}
+
+// ErrGroupFormat represtents failure in parsing a class group given in a wrong format
+type ErrGroupFormat struct {
+	Group string
+}
+
+func newErrGroupFormat(Group string) *ErrGroupFormat {
+	return &ErrGroupFormat{
+		Group: Group,
+	}
+}
+func (e *ErrGroupFormat) Error() string {
+	return "Groups must be given in the <course id>:<group number> format"
+}
+
+// ErrRoleRuleNotFound represtents failure in removing a non-existant role rule
+type ErrRoleRuleNotFound struct {
+	ID int
+}
+
+func newErrRoleRuleNotFound(ID int) *ErrRoleRuleNotFound {
+	return &ErrRoleRuleNotFound{
+		ID: ID,
+	}
+}
+func (e *ErrRoleRuleNotFound) Error() string {
+	return "No role rule with such ID specified."
+}
+
+// ErrCategoryNotFound represtents failure in attempt to get a category that does not exist on a server
+type ErrCategoryNotFound struct {
+	CategoryID string
+	GuildID    string
+}
+
+func newErrCategoryNotFound(CategoryID string, GuildID string) *ErrCategoryNotFound {
+	return &ErrCategoryNotFound{
+		CategoryID: CategoryID,
+		GuildID:    GuildID,
+	}
+}
+func (e *ErrCategoryNotFound) Error() string {
+	return "This category does not belong to this server"
+}
+
+// ErrGraduated represtents failure in authorizing a member as a student because he has graduated
+type ErrGraduated struct {
+	UserID string
+}
+
+func newErrGraduated(UserID string) *ErrGraduated {
+	return &ErrGraduated{
+		UserID: UserID,
+	}
+}
+func (e *ErrGraduated) Error() string {
+	return "You have graduated, you were given the alumni role instead."
+}
+
+// ErrMemberNotFound represtents failure in attempt to get a user who is not a member of a server
+type ErrMemberNotFound struct {
+	error
+	UserID  string
+	GuildID string
+}
+
+func newErrMemberNotFound(cause error, UserID string, GuildID string) *ErrMemberNotFound {
+	return &ErrMemberNotFound{
+		error:   cause,
+		UserID:  UserID,
+		GuildID: GuildID,
+	}
+}
+func (e *ErrMemberNotFound) Error() string {
+	return "This user is not a member of this server"
+}
+
+// ErrGrantNotFound represtents failure in revoking an authorization that was not granted
+type ErrGrantNotFound struct {
+	UserID string
+}
+
+func newErrGrantNotFound(UserID string) *ErrGrantNotFound {
+	return &ErrGrantNotFound{
+		UserID: UserID,
+	}
+}
+func (e *ErrGrantNotFound) Error() string {
+	return "This user was not granted authorization"
+}
+
+// ErrBlockNotFound represtents failure in unblocking an usos account that was not blocked
+type ErrBlockNotFound struct {
+	UsosID string
+}
+
+func newErrBlockNotFound(UsosID string) *ErrBlockNotFound {
+	return &ErrBlockNotFound{
+		UsosID: UsosID,
+	}
+}
+func (e *ErrBlockNotFound) Error() string {
+	return "This usos account is not blocked"
+}
+
+// ErrSetupNotFound represtents failure in confirming or cancelling a setup that was not started or has expired
+type ErrSetupNotFound struct {
+	GuildID string
+}
+
+func newErrSetupNotFound(GuildID string) *ErrSetupNotFound {
+	return &ErrSetupNotFound{
+		GuildID: GuildID,
+	}
+}
+func (e *ErrSetupNotFound) Error() string {
+	return "There is no setup awaiting confirmation, start it again"
+}
+
+// ErrPrivateMessageFailed represtents failure in sending a private message to an user,
+// usually because he does not accept private messages from server members
+type ErrPrivateMessageFailed struct {
+	error
+	UserID string
+}
+
+func newErrPrivateMessageFailed(cause error, UserID string) *ErrPrivateMessageFailed {
+	return &ErrPrivateMessageFailed{
+		error:  cause,
+		UserID: UserID,
+	}
+}
+func (e *ErrPrivateMessageFailed) Error() string {
+	return "Could not send you a private message, allow private messages from server members"
+}
+
+// ErrInvalidCommandPrefix represtents failure in setting a command prefix which can not be used
+type ErrInvalidCommandPrefix struct {
+	Prefix string
+}
+
+func newErrInvalidCommandPrefix(Prefix string) *ErrInvalidCommandPrefix {
+	return &ErrInvalidCommandPrefix{
+		Prefix: Prefix,
+	}
+}
+func (e *ErrInvalidCommandPrefix) Error() string {
+	return "The prefix must be a single short word without backticks, not starting with < or a digit"
+}
+
+// ErrInvalidCommandAlias represtents failure in adding a command alias which can not be used
+type ErrInvalidCommandAlias struct {
+	Alias string
+}
+
+func newErrInvalidCommandAlias(Alias string) *ErrInvalidCommandAlias {
+	return &ErrInvalidCommandAlias{
+		Alias: Alias,
+	}
+}
+func (e *ErrInvalidCommandAlias) Error() string {
+	return "An alias must be a single word not starting with -"
+}
+
+// ErrCommandAliasTaken represtents failure in adding a command alias which is already a command or an alias
+type ErrCommandAliasTaken struct {
+	Alias string
+}
+
+func newErrCommandAliasTaken(Alias string) *ErrCommandAliasTaken {
+	return &ErrCommandAliasTaken{
+		Alias: Alias,
+	}
+}
+func (e *ErrCommandAliasTaken) Error() string {
+	return e.Alias + " is already a command or an alias"
+}
+
+// ErrCommandAliasNotFound represtents failure in removing a command alias that does not exist
+type ErrCommandAliasNotFound struct {
+	Alias string
+}
+
+func newErrCommandAliasNotFound(Alias string) *ErrCommandAliasNotFound {
+	return &ErrCommandAliasNotFound{
+		Alias: Alias,
+	}
+}
+func (e *ErrCommandAliasNotFound) Error() string {
+	return "There is no alias " + e.Alias
+}
+
+// ErrCommandNotFound represtents failure in finding a command with the given path
+type ErrCommandNotFound struct {
+	Path string
+}
+
+func newErrCommandNotFound(Path string) *ErrCommandNotFound {
+	return &ErrCommandNotFound{
+		Path: Path,
+	}
+}
+func (e *ErrCommandNotFound) Error() string {
+	return "There is no command " + e.Path
+}
+
+// ErrPermissionNotFound represtents failure in revoking a permission to run a command that was not granted
+type ErrPermissionNotFound struct {
+	Path string
+}
+
+func newErrPermissionNotFound(Path string) *ErrPermissionNotFound {
+	return &ErrPermissionNotFound{
+		Path: Path,
+	}
+}
+func (e *ErrPermissionNotFound) Error() string {
+	return "The command " + e.Path + " was not granted to them"
+}
+
 // IsNotFound checks if given error is a not found error (on discordgo package and this package)
 func IsNotFound(err error) bool {
 	switch err.(type) {
-	case *ErrChannelNotFound, *ErrLogChannelNotFound, *ErrRoleNotFound, *ErrAuthorizeRoleNotFound:
+	case *ErrChannelNotFound, *ErrLogChannelNotFound, *ErrRoleNotFound, *ErrAuthorizeRoleNotFound, *ErrCategoryNotFound,
+		*ErrMemberNotFound:
 		return true
 	case *discordgo.RESTError:
 		code := err.(*discordgo.RESTError).Response.StatusCode
@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// fallbackThreadArchiveMinutes is the inactivity after which fallback threads are archived
+const fallbackThreadArchiveMinutes = 24 * 60
+
+// msgMember sends a private message to the member. If the member does not accept private messages,
+// the failure is reported to the guild's log channels and the message is sent in a private thread
+// in the guild's fallback channel instead, if it has one.
+func (bot *UsosBot) msgMember(member *discordgo.Member, msg *discordgo.MessageSend) error {
+	dmErr := bot.privMsgDiscordComplex(member.User.ID, msg)
+	if dmErr == nil {
+		return nil
+	}
+	log.Println(dmErr)
+
+	bot.mu.Lock()
+	channelID := bot.getGuildUsosInfo(member.GuildID).DMFallbackChannelID
+	bot.mu.Unlock()
+	if channelID == "" {
+		err := bot.logDiscord(member.GuildID, fmt.Sprintf(
+			"Could not send a private message to <@%s>, set a fallback channel to reach such members", member.User.ID))
+		if err != nil {
+			return err
+		}
+		return dmErr
+	}
+
+	thread, err := bot.ThreadStartComplex(channelID, &discordgo.ThreadStart{
+		Name:                "verification-" + member.User.Username,
+		AutoArchiveDuration: fallbackThreadArchiveMinutes,
+		Type:                discordgo.ChannelTypeGuildPrivateThread,
+		Invitable:           false,
+	})
+	if err != nil {
+		if IsNotFound(err) {
+			// channel was deleted, unless another one was set in the meantime
+			bot.mu.Lock()
+			guildInfo := bot.getGuildUsosInfo(member.GuildID)
+			if guildInfo.DMFallbackChannelID == channelID {
+				guildInfo.DMFallbackChannelID = ""
+			}
+			bot.mu.Unlock()
+		}
+		return err
+	}
+	err = bot.ThreadMemberAdd(thread.ID, member.User.ID)
+	if err != nil {
+		return err
+	}
+	threadMsg := *msg
+	threadMsg.Content = fmt.Sprintf("<@%s> %s", member.User.ID, msg.Content)
+	_, err = bot.ChannelMessageSendComplex(thread.ID, &threadMsg)
+	if err != nil {
+		return err
+	}
+
+	return bot.logDiscord(member.GuildID, fmt.Sprintf(
+		"Could not send a private message to <@%s>, sent it in <#%s> instead", member.User.ID, thread.ID))
+}
+
+// msgMemberText sends a private message with the given text to the member,
+// falling back to a private thread like msgMember
+func (bot *UsosBot) msgMemberText(member *discordgo.Member, text string) error {
+	return bot.msgMember(member, &discordgo.MessageSend{Content: text})
+}
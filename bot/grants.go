@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// grant represents an authorization given manually to a member without usos account
+type grant struct {
+	GrantedBy string
+	Reason    string
+	GrantedAt time.Time
+}
+
+// grantAuthorization authorizes the member without usos and records who granted it and why
+func (bot *UsosBot) grantAuthorization(member *discordgo.Member, grantedBy string, reason string) error {
+	err := bot.authorizeMember(member, nil)
+	if err != nil {
+		return err
+	}
+	bot.mu.Lock()
+	bot.removeUnauthorizedUser(member.User.ID, member.GuildID)
+	bot.getGuildUsosInfo(member.GuildID).Grants[member.User.ID] = &grant{
+		GrantedBy: grantedBy,
+		Reason:    reason,
+		GrantedAt: time.Now(),
+	}
+	bot.mu.Unlock()
+
+	msg := fmt.Sprintf("<@%s> granted authorization to <@%s>", grantedBy, member.User.ID)
+	if reason != "" {
+		msg += ", reason: " + reason
+	}
+	return bot.logDiscord(member.GuildID, msg)
+}
+
+// revokeGrant takes away the authorization granted manually to the member,
+// members verified with usos keep their authorization
+func (bot *UsosBot) revokeGrant(guildID string, userID string, revokedBy string) error {
+	bot.mu.Lock()
+	guildInfo := bot.getGuildUsosInfo(guildID)
+	granted := guildInfo.Grants[userID] != nil
+	delete(guildInfo.Grants, userID)
+	verified := guildInfo.VerifiedMembers[userID] != nil
+	bot.mu.Unlock()
+	if !granted {
+		return newErrGrantNotFound(userID)
+	}
+
+	if !verified {
+		err := bot.removeAuthorizationRoles(guildID, userID)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+	}
+	return bot.logDiscord(guildID, fmt.Sprintf("<@%s> revoked authorization granted to <@%s>", revokedBy, userID))
+}
@@ -2,9 +2,12 @@ package bot
 
 import (
 	"log"
+	"regexp"
 	"strings"
 
 	"github.com/Ogurczak/discord-usos-auth/bot/commands"
+	"github.com/Ogurczak/discord-usos-auth/usos"
+	"github.com/Ogurczak/discord-usos-auth/utils"
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -15,18 +18,26 @@ func (bot *UsosBot) handlerMessageCreate(session *discordgo.Session, e *discordg
 		return
 	}
 
-	if strings.Fields(e.Content)[0] != "!usos" {
+	bot.mu.Lock()
+	inv := bot.invocation(e)
+	helpSpan := bot.commandSpan(e.GuildID, "help")
+	bot.mu.Unlock()
+	if inv == nil {
+		switch {
+		case e.GuildID == "" && !e.Author.Bot:
+			bot.handleDirectMessage(e)
+		case e.GuildID != "" && !e.Author.Bot && bot.bareMention(e.Content):
+			_, err := bot.ChannelMessageSend(e.ChannelID, "Use "+helpSpan+" to see all commands.")
+			if err != nil {
+				log.Println(err)
+			}
+		}
 		return
 	}
 	log.Println("Command received")
 
-	parser, err := bot.setupCommandParser()
-	if err != nil {
-		log.Println(err)
-		return
-	}
-
-	err = parser.Parse(e)
+	// parsing only reads the command tree, every invocation gets its own context
+	ctx, err := bot.parser.Parse(e, inv)
 	switch err.(type) {
 	case *commands.ErrParse:
 		return
@@ -37,136 +48,312 @@ func (bot *UsosBot) handlerMessageCreate(session *discordgo.Session, e *discordg
 		return
 	}
 
-	if parser.ParsedHelp() {
+	if ctx.ParsedHelp() {
 		return
 	}
 
-	err = parser.Handle(e)
+	err = bot.parser.Handle(ctx)
 	switch err.(type) {
-	case *commands.ErrHandler, *commands.ErrCommandInWrongScope, *commands.ErrUnprivilaged, nil:
+	case *commands.ErrHandler, *commands.ErrCommandInWrongScope, *commands.ErrUnprivilaged, *commands.ErrParse, nil:
 		// no-op
 	default:
 		log.Println(err)
 	}
 }
 
-// handlerReady indicates that the bot is ready
+// verifierPattern matches messages looking like usos verifiers
+var verifierPattern = regexp.MustCompile(`^[0-9]{4,16}$`)
+
+// handleDirectMessage treats direct messages looking like verifiers as verify commands
+// and replies with guidance to any other direct messages
+func (bot *UsosBot) handleDirectMessage(e *discordgo.MessageCreate) {
+	verifier := strings.TrimSpace(e.Content)
+	bot.mu.Lock()
+	pending := len(bot.tokenMap[e.Author.ID]) > 0
+	bot.mu.Unlock()
+
+	var reply string
+	switch {
+	case pending && verifierPattern.MatchString(verifier):
+		results, err := bot.finalizeAuthorization(e.Author, verifier)
+		switch err.(type) {
+		case nil:
+			reply = bot.authorizationSummary(results)
+		case *ErrUnregisteredUnauthorizedUser, *usos.ErrUnableToCall, *ErrWrongVerifier:
+			reply = err.Error()
+		default:
+			log.Println(err)
+			return
+		}
+	case pending:
+		reply = "Send me just the verification code you were given after authorizing on USOS, " +
+			"or abort the verification using the " + utils.DiscordCodeSpan("!usos verify -a") + " command."
+	default:
+		reply = "You have no pending verification. To get verified, click the button on the server's " +
+			"authorization message. Use " + utils.DiscordCodeSpan("!usos help") + " to see all commands."
+	}
+	_, err := bot.ChannelMessageSend(e.ChannelID, reply)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// handlerReady indicates that the bot is ready and registers its slash commands
 func (bot *UsosBot) handlerReady(session *discordgo.Session, e *discordgo.Ready) {
 	log.Println("Ready")
+	appCommands, err := bot.parser.ApplicationCommands()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	_, err = bot.ApplicationCommandBulkOverwrite(e.User.ID, "", appCommands)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// handlerInteractionCreate handles slash commands, clicks on the bot's buttons and submitted modals
+func (bot *UsosBot) handlerInteractionCreate(session *discordgo.Session, e *discordgo.InteractionCreate) {
+	switch e.Type {
+	case discordgo.InteractionApplicationCommand:
+		log.Println("Slash command received")
+		bot.handleSlashCommand(e)
+	case discordgo.InteractionMessageComponent:
+		log.Println("Component interaction received")
+		bot.handleComponent(e)
+	case discordgo.InteractionModalSubmit:
+		log.Println("Modal submitted")
+		bot.handleModalSubmit(e)
+	}
+}
+
+// handleSlashCommand handles slash commands the same way as the text commands
+func (bot *UsosBot) handleSlashCommand(e *discordgo.InteractionCreate) {
+	// the handlers answer in the channel or through the context's response to the interaction
+	err := bot.deferEphemeral(e)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	status := "Done"
+	defer func() {
+		err := bot.interactionResponseEditLong(e.Interaction, status)
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	bot.mu.Lock()
+	inv := bot.slashInvocation(e.GuildID)
+	bot.mu.Unlock()
+	ctx, err := bot.parser.ParseInteraction(e, inv)
+	switch err := err.(type) {
+	case nil:
+		// no-op
+	case *commands.ErrParse:
+		status = "Failed"
+		if err.Usage != "" {
+			status = err.Usage
+		}
+		return
+	default:
+		log.Println(err)
+		status = "Failed"
+		return
+	}
+
+	err = bot.parser.Handle(ctx)
+	switch err.(type) {
+	case nil:
+		if ctx.Response != "" {
+			status = ctx.Response
+		}
+	case *commands.ErrParse, *commands.ErrHandler, *commands.ErrCommandInWrongScope, *commands.ErrUnprivilaged:
+		// errors meant for the invoker are left in the response
+		status = "Failed"
+		if errParse, ok := err.(*commands.ErrParse); ok && errParse.Usage != "" {
+			status = errParse.Usage
+		} else if ctx.Response != "" {
+			status = ctx.Response
+		}
+	default:
+		log.Println(err)
+		status = "Failed"
+	}
 }
 
 // handlerReactionAdd handles reactions added to bot's messages
 func (bot *UsosBot) handlerReactionAdd(session *discordgo.Session, e *discordgo.MessageReactionAdd) {
 	log.Println("Reaction add")
-	guildInfo := bot.getGuildUsosInfo(e.GuildID)
-	if guildInfo.AuthorizeMessegeIDs[e.ChannelID][e.MessageID] {
+	bot.mu.Lock()
+	authorizeMessage := bot.getGuildUsosInfo(e.GuildID).AuthorizeMessegeIDs[e.ChannelID][e.MessageID]
+	bot.mu.Unlock()
+	if authorizeMessage {
 		_, err := bot.ChannelMessage(e.ChannelID, e.MessageID)
 		if err != nil {
 			if IsNotFound(err) {
 				// message was deleted, forget it
-				delete(guildInfo.AuthorizeMessegeIDs[e.ChannelID], e.MessageID)
+				bot.mu.Lock()
+				delete(bot.getGuildUsosInfo(e.GuildID).AuthorizeMessegeIDs[e.ChannelID], e.MessageID)
+				bot.mu.Unlock()
 				return
 			}
 			log.Println(err)
 			return
 		}
-		member, err := bot.GuildMember(e.GuildID, e.UserID)
+		member, err := bot.guildMember(e.GuildID, e.UserID)
 		if err != nil {
 			log.Println(err)
 			return
 		}
-		member.GuildID = e.GuildID
-		authorized, err := bot.isAuthorized(member)
+		err = bot.startAuthorization(member, "")
 		if err != nil {
 			log.Println(err)
-			return
-		}
-		if !authorized {
-			err = bot.addUnauthorizedMember(member)
-			switch err.(type) {
-			case *ErrAlreadyRegistered:
-				bot.privMsgDiscord(e.UserID, "Already registered for verification")
-			case nil:
-				// no-op
-			default:
-				log.Println(err)
-				return
-			}
 		}
 	}
 }
 
+// handlerGuildMemberAdd starts authorization of members joining guilds which wish so
+func (bot *UsosBot) handlerGuildMemberAdd(session *discordgo.Session, e *discordgo.GuildMemberAdd) {
+	log.Println("Guild member added")
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+
+	policy := bot.getGuildUsosInfo(e.GuildID).JoinVerification
+	if !policy.Enabled || e.User.Bot {
+		return
+	}
+	bot.scheduleJoinAuthorization(e.GuildID, e.User.ID)
+}
+
 //#region Cleaning handlers
 
 func (bot *UsosBot) handlerChannelDelete(session *discordgo.Session, e *discordgo.ChannelDelete) {
 	log.Println("Channel deleted")
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+
 	guildInfo := bot.getGuildUsosInfo(e.GuildID)
 	delete(guildInfo.LogChannelIDs, e.Channel.ID)
+	if e.Channel.ID == guildInfo.DMFallbackChannelID {
+		guildInfo.DMFallbackChannelID = ""
+	}
+	bot.forgetProvisionedResource(e.GuildID, e.Channel.ID)
 }
 
 func (bot *UsosBot) handlerGuildMemberRemove(session *discordgo.Session, e *discordgo.GuildMemberRemove) {
 	log.Println("Guild member removed")
-	delete(bot.tokenMap, e.User.ID)
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+
+	guildInfo := bot.getGuildUsosInfo(e.GuildID)
+	delete(guildInfo.VerifiedMembers, e.User.ID)
+	delete(guildInfo.Grants, e.User.ID)
+	guildInfo.CommandPermissions.RemoveUser(e.User.ID)
+	err := bot.removeUnauthorizedUser(e.User.ID, e.GuildID)
+	switch err.(type) {
+	case *ErrUnregisteredUserNotFound, nil:
+		// no-op
+	default:
+		log.Println(err)
+	}
 }
 
 func (bot *UsosBot) handlerGuildRoleDelete(session *discordgo.Session, e *discordgo.GuildRoleDelete) {
 	log.Println("Guild role deleted")
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+
 	guildInfo := bot.getGuildUsosInfo(e.GuildID)
 	if e.RoleID == guildInfo.AuthorizeRoleID {
 		guildInfo.AuthorizeRoleID = ""
 	}
+	if e.RoleID == guildInfo.Lifecycle.AlumniRoleID {
+		guildInfo.Lifecycle.AlumniRoleID = ""
+		guildInfo.Lifecycle.Enabled = false
+	}
+	bot.removeRoleRules(e.GuildID, e.RoleID)
+	guildInfo.CommandPermissions.RemoveRole(e.RoleID)
+	bot.forgetProvisionedResource(e.GuildID, e.RoleID)
 }
 
 func (bot *UsosBot) handlerMessageDelete(session *discordgo.Session, e *discordgo.MessageDelete) {
 	log.Println("Mesage deleted")
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+
 	guildInfo := bot.getGuildUsosInfo(e.GuildID)
 	delete(guildInfo.AuthorizeMessegeIDs[e.ChannelID], e.Message.ID)
 }
 
 func (bot *UsosBot) handlerGuildDelete(session *discordgo.Session, e *discordgo.GuildDelete) {
 	log.Println("Guild deleted")
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+
 	delete(bot.guildUsosInfos, e.Guild.ID)
+	for userID := range bot.tokenMap {
+		bot.removeUnauthorizedUser(userID, e.Guild.ID)
+	}
 }
 
 func (bot *UsosBot) handlerGuildCreate(session *discordgo.Session, e *discordgo.GuildCreate) {
 	log.Println("Guild created")
+	bot.mu.Lock()
 	guildInfo := bot.getGuildUsosInfo(e.Guild.ID)
-
-	// clean deleted authorize message ids
+	messageIDs := make(map[string][]string)
 	for channelID, messageMap := range guildInfo.AuthorizeMessegeIDs {
+		if len(messageMap) == 0 {
+			delete(guildInfo.AuthorizeMessegeIDs, channelID)
+		}
 		for messageID := range messageMap {
+			messageIDs[channelID] = append(messageIDs[channelID], messageID)
+		}
+	}
+	authorizeRoleID := guildInfo.AuthorizeRoleID
+	logChannelIDs := make([]string, 0, len(guildInfo.LogChannelIDs))
+	for logChannelID := range guildInfo.LogChannelIDs {
+		logChannelIDs = append(logChannelIDs, logChannelID)
+	}
+	bot.mu.Unlock()
+
+	// clean deleted authorize message ids
+	for channelID, ids := range messageIDs {
+		for _, messageID := range ids {
 			_, err := bot.ChannelMessage(channelID, messageID)
 			if err != nil {
 				if IsNotFound(err) {
-					delete(messageMap, messageID)
+					bot.mu.Lock()
+					messageMap := bot.getGuildUsosInfo(e.Guild.ID).AuthorizeMessegeIDs
+					delete(messageMap[channelID], messageID)
+					if len(messageMap[channelID]) == 0 {
+						delete(messageMap, channelID)
+					}
+					bot.mu.Unlock()
 				} else {
 					log.Println(err)
 				}
 			}
 		}
-		if len(messageMap) == 0 {
-			delete(guildInfo.AuthorizeMessegeIDs, channelID)
-		}
 	}
 
 	// clean authorize role
-	if guildInfo.AuthorizeRoleID != "" {
-		_, err := bot.guildRole(e.Guild.ID, guildInfo.AuthorizeRoleID)
-		if err != nil {
-			if IsNotFound(err) {
-				guildInfo.AuthorizeRoleID = ""
-			} else {
-				log.Println(err)
-			}
+	if authorizeRoleID != "" {
+		_, err := bot.getAuthorizeRole(e.Guild.ID)
+		if err != nil && !IsNotFound(err) {
+			log.Println(err)
 		}
 	}
 
 	// clean log channels
-	for logChannelID := range guildInfo.LogChannelIDs {
+	for _, logChannelID := range logChannelIDs {
 		_, err := bot.Channel(logChannelID)
 		if err != nil {
 			if IsNotFound(err) {
-				delete(guildInfo.LogChannelIDs, logChannelID)
+				bot.mu.Lock()
+				delete(bot.getGuildUsosInfo(e.Guild.ID).LogChannelIDs, logChannelID)
+				bot.mu.Unlock()
 			} else {
 				log.Println(err)
 			}
@@ -0,0 +1,118 @@
+package bot
+
+import (
+	"time"
+
+	"github.com/Ogurczak/discord-usos-auth/usos"
+	"github.com/bwmarrin/discordgo"
+	"github.com/dghubble/oauth1"
+)
+
+// linkedIdentity represents an usos identity a discord user consented to be remembered
+// across guilds. The access token is kept in memory only so settings files never hold usos credentials,
+// identities older than a guild allows need a new usos login after restarts.
+type linkedIdentity struct {
+	UsosID      string
+	User        *usos.User
+	AccessToken *oauth1.Token `json:"-"`
+	FetchedAt   time.Time
+}
+
+// consentIdentity registers the user's consent to remember his usos identity,
+// the identity itself is linked upon his next authorization
+func (bot *UsosBot) consentIdentity(userID string) {
+	if bot.identities[userID] == nil {
+		bot.identities[userID] = &linkedIdentity{}
+	}
+}
+
+// forgetIdentity withdraws the user's consent and removes his linked identity
+func (bot *UsosBot) forgetIdentity(userID string) error {
+	if bot.identities[userID] == nil {
+		return newErrIdentityNotLinked(userID)
+	}
+	delete(bot.identities, userID)
+	return nil
+}
+
+// updateIdentity updates the user's linked identity if he consented to it
+func (bot *UsosBot) updateIdentity(userID string, usosUser *usos.User, accessToken *oauth1.Token) {
+	identity := bot.identities[userID]
+	if identity == nil {
+		return
+	}
+	identity.UsosID = usosUser.ID
+	identity.User = usosUser
+	identity.AccessToken = accessToken
+	identity.FetchedAt = time.Now()
+}
+
+// refreshIdentity downloads the linked identity's usos data again and returns it
+func (bot *UsosBot) refreshIdentity(userID string) (*usos.User, error) {
+	bot.mu.Lock()
+	var accessToken *oauth1.Token
+	if identity := bot.identities[userID]; identity != nil {
+		accessToken = identity.AccessToken
+	}
+	bot.mu.Unlock()
+	if accessToken == nil {
+		return nil, newErrIdentityNotLinked(userID)
+	}
+
+	usosUser, err := fetchUsosUser(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	bot.mu.Lock()
+	bot.updateIdentity(userID, usosUser, accessToken)
+	bot.mu.Unlock()
+	return usosUser, nil
+}
+
+// linkedUser returns the usos data of the user's linked identity, nil if he has not consented to linking it
+func (bot *UsosBot) linkedUser(userID string) *usos.User {
+	if identity := bot.identities[userID]; identity != nil {
+		return identity.User
+	}
+	return nil
+}
+
+// canReuseIdentity checks if the user's linked identity may be used to authorize him on the given guild
+func (bot *UsosBot) canReuseIdentity(guildID string, userID string) bool {
+	identity := bot.identities[userID]
+	return identity != nil && identity.User != nil && bot.getGuildUsosInfo(guildID).ReuseIdentities
+}
+
+// authorizeWithIdentity authorizes the member using his linked identity,
+// refreshing it first if it is older than the guild allows
+func (bot *UsosBot) authorizeWithIdentity(member *discordgo.Member) error {
+	bot.mu.Lock()
+	canReuse := bot.canReuseIdentity(member.GuildID, member.User.ID)
+	var usosUser *usos.User
+	var fetchedAt time.Time
+	if canReuse {
+		usosUser = bot.identities[member.User.ID].User
+		fetchedAt = bot.identities[member.User.ID].FetchedAt
+	}
+	maxAge := bot.getGuildUsosInfo(member.GuildID).IdentityMaxAge
+	bot.mu.Unlock()
+	if !canReuse {
+		return newErrIdentityNotLinked(member.User.ID)
+	}
+
+	if maxAge > 0 && time.Since(fetchedAt) > maxAge {
+		var err error
+		usosUser, err = bot.refreshIdentity(member.User.ID)
+		switch err.(type) {
+		case nil:
+			// no-op
+		case *usos.ErrUnableToCall, *usos.ErrHTTP, *ErrIdentityNotLinked:
+			// access was revoked, user has to authorize the regular way
+			return newErrIdentityStale(err, member.User.ID)
+		default:
+			return err
+		}
+	}
+
+	return bot.authorizeWithUsosUser(member.GuildID, member.User, usosUser)
+}
@@ -0,0 +1,49 @@
+package bot
+
+import (
+	"log"
+	"time"
+)
+
+// joinAuthorizationInterval is the minimal interval between authorizations started by members joining a guild,
+// so that mass joins do not exceed discord's rate limits
+const joinAuthorizationInterval = 5 * time.Second
+
+// joinVerificationPolicy represents a guild's settings of starting authorization when members join
+type joinVerificationPolicy struct {
+	Enabled      bool
+	DelaySeconds int    // delay between joining and receiving the instructions
+	WelcomeText  string // text preceding the instructions
+}
+
+// scheduleJoinAuthorization schedules starting authorization of the member who joined the guild
+// respecting the guild's delay and the rate limit
+func (bot *UsosBot) scheduleJoinAuthorization(guildID string, userID string) {
+	policy := bot.getGuildUsosInfo(guildID).JoinVerification
+
+	at := time.Now().Add(time.Duration(policy.DelaySeconds) * time.Second)
+	if slot := bot.joinSlots[guildID]; slot.After(at) {
+		at = slot
+	}
+	bot.joinSlots[guildID] = at.Add(joinAuthorizationInterval)
+
+	time.AfterFunc(time.Until(at), func() {
+		bot.mu.Lock()
+		policy := bot.getGuildUsosInfo(guildID).JoinVerification
+		bot.mu.Unlock()
+		if !policy.Enabled {
+			return
+		}
+		member, err := bot.guildMember(guildID, userID)
+		if err != nil {
+			if !IsNotFound(err) {
+				log.Println(err)
+			}
+			return
+		}
+		err = bot.startAuthorization(member, policy.WelcomeText)
+		if err != nil {
+			log.Println(err)
+		}
+	})
+}
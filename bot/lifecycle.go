@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Ogurczak/discord-usos-auth/usos"
+	"github.com/bwmarrin/discordgo"
+)
+
+// memberStage represents a verified member's stage in the guild's lifecycle
+type memberStage string
+
+const (
+	// stageStudent is the stage of members actively studying
+	stageStudent memberStage = ""
+	// stageAlumnus is the stage of members who graduated
+	stageAlumnus memberStage = "alumnus"
+)
+
+// lifecyclePolicy represents a guild's settings of transitioning members from students to alumni
+type lifecyclePolicy struct {
+	Enabled         bool
+	AlumniRoleID    string
+	RemoveAfterDays int // days after which alumni lose the alumni role, 0 keeps it forever
+}
+
+// applyLifecycle transitions an already verified member to alumni if his usos data shows he graduated.
+// Returns true if the member was transitioned and should not be authorized as a student.
+func (bot *UsosBot) applyLifecycle(member *discordgo.Member, usosUser *usos.User) (bool, error) {
+	bot.mu.Lock()
+	guildInfo := bot.getGuildUsosInfo(member.GuildID)
+	verification := guildInfo.VerifiedMembers[member.User.ID]
+	enabled := guildInfo.Lifecycle.Enabled && verification != nil
+	alumnus := enabled && verification.Stage == stageAlumnus
+	alumniRoleID := guildInfo.Lifecycle.AlumniRoleID
+	bot.mu.Unlock()
+	if !enabled || !usosUser.IsAlumnus() {
+		return false, nil
+	}
+	if alumnus {
+		return true, nil
+	}
+
+	_, err := bot.guildRole(member.GuildID, alumniRoleID)
+	if err != nil {
+		return false, err
+	}
+	err = bot.removeAuthorizationRoles(member.GuildID, member.User.ID)
+	if err != nil && !IsNotFound(err) {
+		return false, err
+	}
+	err = bot.GuildMemberRoleAdd(member.GuildID, member.User.ID, alumniRoleID)
+	if err != nil {
+		return false, err
+	}
+
+	bot.mu.Lock()
+	verification = bot.getGuildUsosInfo(member.GuildID).VerifiedMembers[member.User.ID]
+	if verification != nil {
+		verification.Stage = stageAlumnus
+		verification.StageSince = time.Now()
+		verification.ExpiresAt = time.Time{}
+		verification.ExpiredAt = time.Time{}
+	}
+	bot.mu.Unlock()
+
+	return true, bot.logDiscord(member.GuildID, fmt.Sprintf("<@%s> has graduated: student → alumnus", member.User.ID))
+}
+
+// removeAlumni takes the alumni role away from members who have been alumni longer than the guild allows
+func (bot *UsosBot) removeAlumni(guildID string) error {
+	bot.mu.Lock()
+	guildInfo := bot.getGuildUsosInfo(guildID)
+	lifecycle := guildInfo.Lifecycle
+	userIDs := make([]string, 0)
+	for userID, verification := range guildInfo.VerifiedMembers {
+		if !lifecycle.Enabled || lifecycle.RemoveAfterDays == 0 || verification.Stage != stageAlumnus ||
+			time.Now().Before(verification.StageSince.AddDate(0, 0, lifecycle.RemoveAfterDays)) {
+			continue
+		}
+		userIDs = append(userIDs, userID)
+	}
+	bot.mu.Unlock()
+
+	for _, userID := range userIDs {
+		err := bot.GuildMemberRoleRemove(guildID, userID, lifecycle.AlumniRoleID)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+		bot.mu.Lock()
+		delete(bot.getGuildUsosInfo(guildID).VerifiedMembers, userID)
+		bot.mu.Unlock()
+
+		err = bot.logDiscord(guildID, fmt.Sprintf("<@%s>'s alumni period has ended: alumnus → removed", userID))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
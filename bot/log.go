@@ -2,6 +2,8 @@ package bot
 
 import (
 	"strings"
+
+	"github.com/bwmarrin/discordgo"
 )
 
 const maxMsgLen = 2000
@@ -114,6 +116,27 @@ func fragmentMsg(text *string) []string {
 
 // logDiscord logs a message to all log channels of a guild
 func (bot *UsosBot) logDiscord(guildID string, text string) error {
+	bot.mu.Lock()
+	channelIDs := bot.logChannelIDs(guildID)
+	bot.mu.Unlock()
+	for _, channelID := range channelIDs {
+		channel, err := bot.getLogChannel(guildID, channelID)
+		if err != nil {
+			if IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		err = bot.channelMessageSendLong(channel.ID, text)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// channelMessageSendLong sends a message to the channel, fragmenting it if it exceeds discord's length limit
+func (bot *UsosBot) channelMessageSendLong(channelID string, text string) error {
 	var msgs []string
 	if len(text) > maxMsgLen {
 		msgs = fragmentMsg(&text)
@@ -121,19 +144,36 @@ func (bot *UsosBot) logDiscord(guildID string, text string) error {
 		msgs = []string{text}
 	}
 
-	for channelID := range bot.getGuildUsosInfo(guildID).LogChannelIDs {
-		channel, err := bot.getLogChannel(guildID, channelID)
+	for _, msg := range msgs {
+		_, err := bot.ChannelMessageSend(channelID, msg)
 		if err != nil {
-			if IsNotFound(err) {
-				continue
-			}
 			return err
 		}
-		for _, msg := range msgs {
-			_, err = bot.ChannelMessageSend(channel.ID, msg)
-			if err != nil {
-				return err
-			}
+	}
+	return nil
+}
+
+// interactionResponseEditLong replaces the deferred ephemeral response to the interaction with the text,
+// fragments exceeding the maximal message length are sent as ephemeral follow-ups
+func (bot *UsosBot) interactionResponseEditLong(interaction *discordgo.Interaction, text string) error {
+	var msgs []string
+	if len(text) > maxMsgLen {
+		msgs = fragmentMsg(&text)
+	} else {
+		msgs = []string{text}
+	}
+
+	_, err := bot.InteractionResponseEdit(interaction, &discordgo.WebhookEdit{Content: msgs[0]})
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs[1:] {
+		_, err = bot.FollowupMessageCreate(interaction, true, &discordgo.WebhookParams{
+			Content: msg,
+			Flags:   uint64(discordgo.MessageFlagsEphemeral),
+		})
+		if err != nil {
+			return err
 		}
 	}
 	return nil
@@ -141,8 +181,10 @@ func (bot *UsosBot) logDiscord(guildID string, text string) error {
 
 // addLogChannel adds a channel to log to authorization data from the guild
 func (bot *UsosBot) addLogChannel(guildID string, channelID string) error {
-	guildInfo := bot.getGuildUsosInfo(guildID)
-	if guildInfo.LogChannelIDs[channelID] {
+	bot.mu.Lock()
+	present := bot.getGuildUsosInfo(guildID).LogChannelIDs[channelID]
+	bot.mu.Unlock()
+	if present {
 		return newErrLogChannelPresent(channelID, guildID)
 	}
 
@@ -154,7 +196,9 @@ func (bot *UsosBot) addLogChannel(guildID string, channelID string) error {
 	// only add this guild's channels
 	for _, guildChannel := range guildChannels {
 		if guildChannel.ID == channelID {
-			guildInfo.LogChannelIDs[channelID] = true
+			bot.mu.Lock()
+			bot.getGuildUsosInfo(guildID).LogChannelIDs[channelID] = true
+			bot.mu.Unlock()
 			return nil
 		}
 	}
@@ -0,0 +1,164 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Ogurczak/discord-usos-auth/usos"
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxNicknameLen is discord's limit of nickname length
+const maxNicknameLen = 32
+
+// renderNickname fills the nickname template with the usos user's data.
+// Supported placeholders: {first_name}, {last_name}, {first_name_initial}, {last_name_initial},
+// {programme} and {usos_id}
+func renderNickname(template string, usosUser *usos.User) string {
+	programme := ""
+	for _, p := range usosUser.Programmes {
+		if p.Status == "" || p.Status == usos.ProgrammeStatusActive {
+			programme = p.Name
+			break
+		}
+	}
+
+	replacer := strings.NewReplacer(
+		"{first_name}", usosUser.FirstName,
+		"{last_name}", usosUser.LastName,
+		"{first_name_initial}", initial(usosUser.FirstName),
+		"{last_name_initial}", initial(usosUser.LastName),
+		"{programme}", programme,
+		"{usos_id}", usosUser.ID,
+	)
+	nickname := strings.TrimSpace(replacer.Replace(template))
+
+	if utf8.RuneCountInString(nickname) > maxNicknameLen {
+		nickname = strings.TrimSpace(string([]rune(nickname)[:maxNicknameLen]))
+	}
+	return nickname
+}
+
+// initial returns the first letter of the name
+func initial(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	if size == 0 || r == utf8.RuneError {
+		return ""
+	}
+	return string(r)
+}
+
+// canManageMember checks if the bot's role hierarchy allows it to manage the member
+func (bot *UsosBot) canManageMember(member *discordgo.Member) (bool, error) {
+	guild, err := bot.Guild(member.GuildID)
+	if err != nil {
+		return false, err
+	}
+	if guild.OwnerID == member.User.ID {
+		return false, nil
+	}
+
+	botMember, err := bot.GuildMember(member.GuildID, bot.State.User.ID)
+	if err != nil {
+		return false, err
+	}
+	roles, err := bot.GuildRoles(member.GuildID)
+	if err != nil {
+		return false, err
+	}
+	positions := make(map[string]int)
+	for _, role := range roles {
+		positions[role.ID] = role.Position
+	}
+
+	return highestPosition(botMember.Roles, positions) > highestPosition(member.Roles, positions), nil
+}
+
+// highestPosition returns the highest position among the roles
+func highestPosition(roleIDs []string, positions map[string]int) int {
+	highest := 0
+	for _, roleID := range roleIDs {
+		if positions[roleID] > highest {
+			highest = positions[roleID]
+		}
+	}
+	return highest
+}
+
+// applyNickname sets the member's nickname according to the guild's template.
+// Returns false if the bot's role hierarchy does not allow it.
+func (bot *UsosBot) applyNickname(member *discordgo.Member, usosUser *usos.User) (bool, error) {
+	bot.mu.Lock()
+	template := bot.getGuildUsosInfo(member.GuildID).NicknameTemplate
+	bot.mu.Unlock()
+	if template == "" || usosUser == nil {
+		return true, nil
+	}
+
+	canManage, err := bot.canManageMember(member)
+	if err != nil || !canManage {
+		return false, err
+	}
+	nickname := renderNickname(template, usosUser)
+	if nickname == member.Nick {
+		return true, nil
+	}
+	return true, bot.GuildMemberNickname(member.GuildID, member.User.ID, nickname)
+}
+
+// applyNicknames sets nicknames of all verified members who linked their usos identities according to
+// the guild's template, returns the number of members whose nicknames could not be set due to the bot's role hierarchy
+func (bot *UsosBot) applyNicknames(guildID string) (int, error) {
+	bot.mu.Lock()
+	usosUsers := make(map[string]*usos.User) // maps user id to his usos data
+	for userID := range bot.getGuildUsosInfo(guildID).VerifiedMembers {
+		if usosUser := bot.linkedUser(userID); usosUser != nil {
+			usosUsers[userID] = usosUser
+		}
+	}
+	bot.mu.Unlock()
+
+	skipped := 0
+	for userID, usosUser := range usosUsers {
+		member, err := bot.GuildMember(guildID, userID)
+		if err != nil {
+			if IsNotFound(err) {
+				continue
+			}
+			return skipped, err
+		}
+		member.GuildID = guildID
+
+		applied, err := bot.applyNickname(member, usosUser)
+		if err != nil {
+			return skipped, err
+		}
+		if !applied {
+			skipped++
+		}
+	}
+	return skipped, nil
+}
+
+// applyNicknameOrReport sets the member's nickname and reports to the log channels if it could not be done,
+// a nickname is not worth failing the member's authorization over
+func (bot *UsosBot) applyNicknameOrReport(member *discordgo.Member, usosUser *usos.User) {
+	applied, err := bot.applyNickname(member, usosUser)
+	var report string
+	switch {
+	case err != nil:
+		log.Println(err)
+		report = fmt.Sprintf("Could not set <@%s>'s nickname, make sure the bot may manage nicknames", member.User.ID)
+	case !applied:
+		report = fmt.Sprintf("Could not set <@%s>'s nickname, the member is above the bot in the role hierarchy",
+			member.User.ID)
+	default:
+		return
+	}
+	err = bot.logDiscord(member.GuildID, report)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,33 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/Ogurczak/discord-usos-auth/usos"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderNickname(t *testing.T) {
+	usosUser := &usos.User{
+		ID:        "123123",
+		FirstName: "Witold",
+		LastName:  "Wysota",
+		Programmes: []*usos.Programme{
+			{Name: "101C-ISP-IN", Status: usos.ProgrammeStatusGraduatedEndOfStudy},
+			{Name: "103C-ISP-IN", Status: usos.ProgrammeStatusActive},
+		},
+	}
+
+	assert.Equal(t, "Witold W. (103C-ISP-IN)",
+		renderNickname("{first_name} {last_name_initial}. ({programme})", usosUser))
+	assert.Equal(t, "Ł. Żółć",
+		renderNickname("{first_name_initial}. {last_name}", &usos.User{FirstName: "Łukasz", LastName: "Żółć"}))
+}
+
+func TestRenderNicknameTruncated(t *testing.T) {
+	usosUser := &usos.User{FirstName: "Konstantynopolitańczykowianeczka", LastName: "Wysota"}
+
+	nickname := renderNickname("{first_name} {last_name}", usosUser)
+	assert.Equal(t, "Konstantynopolitańczykowianeczka", nickname)
+	assert.LessOrEqual(t, len([]rune(nickname)), maxNicknameLen)
+}
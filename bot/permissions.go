@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// grantCommand allows the role or the user, whichever is not empty, to run the command with the given path
+// and its subcommands in the guild despite them requiring privilages
+func (bot *UsosBot) grantCommand(guildID string, path string, roleID string, userID string) error {
+	cmd := bot.parser.Lookup(path)
+	if cmd == nil || cmd.GetParent() == nil {
+		return newErrCommandNotFound(path)
+	}
+	if roleID != "" {
+		_, err := bot.guildRole(guildID, roleID)
+		if err != nil {
+			return err
+		}
+	}
+	if userID != "" {
+		_, err := bot.guildMember(guildID, userID)
+		if err != nil {
+			return err
+		}
+	}
+	bot.mu.Lock()
+	bot.getGuildUsosInfo(guildID).CommandPermissions.Grant(path, roleID, userID)
+	bot.mu.Unlock()
+	return nil
+}
+
+// revokeCommand takes back the permission to run the command with the given path from the role or the user
+func (bot *UsosBot) revokeCommand(guildID string, path string, roleID string, userID string) error {
+	if !bot.getGuildUsosInfo(guildID).CommandPermissions.Revoke(path, roleID, userID) {
+		return newErrPermissionNotFound(path)
+	}
+	return nil
+}
+
+// describeCommandPermissions returns the guild's permission grants, one command per line
+func (bot *UsosBot) describeCommandPermissions(guildID string) string {
+	perms := bot.getGuildUsosInfo(guildID).CommandPermissions
+	paths := make([]string, 0, len(perms))
+	for path := range perms {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	lines := make([]string, 0, len(paths))
+	for _, path := range paths {
+		holders := make([]string, 0)
+		for roleID := range perms[path].RoleIDs {
+			holders = append(holders, fmt.Sprintf("<@&%s>", roleID))
+		}
+		for userID := range perms[path].UserIDs {
+			holders = append(holders, fmt.Sprintf("<@%s>", userID))
+		}
+		sort.Strings(holders)
+		lines = append(lines, fmt.Sprintf("`%s` - %s", path, strings.Join(holders, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
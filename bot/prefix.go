@@ -0,0 +1,128 @@
+package bot
+
+import (
+	"strings"
+
+	"github.com/Ogurczak/discord-usos-auth/bot/commands"
+	"github.com/Ogurczak/discord-usos-auth/utils"
+	"github.com/bwmarrin/discordgo"
+)
+
+// defaultCommandPrefix precedes commands in private chats and in guilds which did not set their own prefix
+const defaultCommandPrefix = "!usos"
+
+// maxCommandPrefixLen is the maximal length of guilds' command prefixes
+const maxCommandPrefixLen = 16
+
+// commandPrefix returns the prefix of commands in the guild
+func (bot *UsosBot) commandPrefix(guildID string) string {
+	if guildID != "" {
+		if prefix := bot.getGuildUsosInfo(guildID).CommandPrefix; prefix != "" {
+			return prefix
+		}
+	}
+	return defaultCommandPrefix
+}
+
+// commandSpan returns the command preceded by the guild's prefix, formatted as code
+func (bot *UsosBot) commandSpan(guildID string, command string) string {
+	return utils.DiscordCodeSpan(commands.NewInvocation(bot.commandPrefix(guildID), nil, nil).Prefix + command)
+}
+
+// invocation returns the invocation of commands the message starts with,
+// either the guild's prefix or a mention of the bot, or nil if the message is not a command.
+// Private messages accept prefixes of all guilds as messages about a guild use its prefix,
+// a bare mention of the bot is not a command.
+func (bot *UsosBot) invocation(e *discordgo.MessageCreate) *commands.Invocation {
+	if bot.bareMention(e.Content) {
+		return nil
+	}
+
+	// copied as the message is parsed and handled outside of the lock
+	aliases := make(map[string]string)
+	perms := make(commands.Permissions)
+	if e.GuildID != "" {
+		guildInfo := bot.getGuildUsosInfo(e.GuildID)
+		for alias, path := range guildInfo.CommandAliases {
+			aliases[alias] = path
+		}
+		perms = guildInfo.CommandPermissions.Copy()
+	}
+
+	invocations := []*commands.Invocation{
+		commands.NewInvocation(bot.commandPrefix(e.GuildID), aliases, perms),
+		{Prefix: "<@" + bot.State.User.ID + "> ", Aliases: aliases, Permissions: perms},
+		{Prefix: "<@!" + bot.State.User.ID + "> ", Aliases: aliases, Permissions: perms},
+	}
+	if e.GuildID == "" {
+		for _, guildInfo := range bot.guildUsosInfos {
+			// prefixes set before they were validated may clash with verifiers sent in private messages
+			if validateCommandPrefix(guildInfo.CommandPrefix) == nil {
+				invocations = append(invocations, commands.NewInvocation(guildInfo.CommandPrefix, aliases, perms))
+			}
+		}
+	}
+	for _, inv := range invocations {
+		if _, ok := inv.Match(e.Content); ok {
+			return inv
+		}
+	}
+	return nil
+}
+
+// bareMention checks if the message consists of a mention of the bot only
+func (bot *UsosBot) bareMention(content string) bool {
+	content = strings.TrimSpace(content)
+	return content == "<@"+bot.State.User.ID+">" || content == "<@!"+bot.State.User.ID+">"
+}
+
+// slashInvocation returns the invocation of slash commands in the guild
+func (bot *UsosBot) slashInvocation(guildID string) *commands.Invocation {
+	perms := make(commands.Permissions)
+	if guildID != "" {
+		perms = bot.getGuildUsosInfo(guildID).CommandPermissions.Copy()
+	}
+	return &commands.Invocation{Prefix: "/", Permissions: perms}
+}
+
+// validateCommandPrefix checks if the prefix can be used as a guild's command prefix,
+// prefixes starting with a digit would be confused with verifiers sent in private messages
+func validateCommandPrefix(prefix string) error {
+	if prefix == "" || len(prefix) > maxCommandPrefixLen || strings.ContainsAny(prefix, " \t\n`") ||
+		strings.HasPrefix(prefix, "<") || prefix[0] >= '0' && prefix[0] <= '9' {
+		return newErrInvalidCommandPrefix(prefix)
+	}
+	return nil
+}
+
+// addCommandAlias makes the alias stand for the command with the given path in the guild
+func (bot *UsosBot) addCommandAlias(guildID string, alias string, path string) error {
+	if alias == "" || strings.ContainsAny(alias, " \t\n`") || strings.HasPrefix(alias, "-") {
+		return newErrInvalidCommandAlias(alias)
+	}
+	cmd := bot.parser.Lookup(path)
+	if cmd == nil || cmd.GetParent() == nil {
+		return newErrCommandNotFound(path)
+	}
+	// names of commands take precedence over aliases, such alias would never be used
+	if bot.parser.Lookup(alias) != nil {
+		return newErrCommandAliasTaken(alias)
+	}
+
+	guildInfo := bot.getGuildUsosInfo(guildID)
+	if _, ok := guildInfo.CommandAliases[alias]; ok {
+		return newErrCommandAliasTaken(alias)
+	}
+	guildInfo.CommandAliases[alias] = strings.Join(strings.Fields(path), " ")
+	return nil
+}
+
+// removeCommandAlias removes the guild's alias
+func (bot *UsosBot) removeCommandAlias(guildID string, alias string) error {
+	guildInfo := bot.getGuildUsosInfo(guildID)
+	if _, ok := guildInfo.CommandAliases[alias]; !ok {
+		return newErrCommandAliasNotFound(alias)
+	}
+	delete(guildInfo.CommandAliases, alias)
+	return nil
+}
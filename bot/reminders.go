@@ -0,0 +1,138 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// reminderInterval is the interval between checks for pending verifications due a reminder
+const reminderInterval = 10 * time.Minute
+
+// defaultRequestTokenLifetime is the time after which usos request tokens are assumed to be expired by default.
+// Usos does not tell when request tokens expire, tokens older than this are replaced by new ones
+// before their authorization links are sent again. Installations expiring them sooner need a shorter lifetime.
+const defaultRequestTokenLifetime = time.Hour
+
+// defaultReminderDelays are the delays after registration at which reminders are sent by default
+var defaultReminderDelays = []time.Duration{time.Hour, 24 * time.Hour}
+
+// reminderPolicy represents a guild's settings of reminding users about their pending verifications
+type reminderPolicy struct {
+	Enabled bool
+	Delays  []time.Duration // delays after registration at which reminders are sent, nil means the default ones
+	Text    string          // text of the reminders, empty means the default one
+}
+
+// delays returns the delays at which reminders are sent
+func (policy *reminderPolicy) delays() []time.Duration {
+	if policy.Delays == nil {
+		return defaultReminderDelays
+	}
+	return policy.Delays
+}
+
+// remindPending reminds users about their pending verifications on guilds which wish so,
+// renewing their authorization links if the request tokens expired
+func (bot *UsosBot) remindPending() {
+	type reminder struct {
+		userID  string
+		guildID string
+		text    string
+	}
+	bot.mu.Lock()
+	reminders := make([]reminder, 0)
+	for userID, pairs := range bot.tokenMap {
+		for guildID, pair := range pairs {
+			if bot.reminderDue(guildID, pair) {
+				reminders = append(reminders, reminder{userID, guildID, bot.getGuildUsosInfo(guildID).Reminders.Text})
+			}
+		}
+	}
+	bot.mu.Unlock()
+
+	for _, r := range reminders {
+		err := bot.remindPendingMember(r.userID, r.guildID, r.text)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// requestTokenLifetime returns the time after which usos request tokens are assumed to be expired
+func (bot *UsosBot) requestTokenLifetime() time.Duration {
+	if bot.RequestTokenLifetime <= 0 {
+		return defaultRequestTokenLifetime
+	}
+	return bot.RequestTokenLifetime
+}
+
+// stopReminders stops reminders about the user's pending verifications without aborting them
+func (bot *UsosBot) stopReminders(userID string) error {
+	pairs := bot.tokenMap[userID]
+	if len(pairs) == 0 {
+		return newErrUnregisteredUserNotFound(userID)
+	}
+	for _, pair := range pairs {
+		pair.Unreminded = true
+	}
+	return nil
+}
+
+// reminderDue checks if a reminder about the pending verification on the guild is due and counts it as sent
+func (bot *UsosBot) reminderDue(guildID string, pair *requestTokenGuildPair) bool {
+	if pair.RegisteredAt.IsZero() {
+		// registered before reminders were introduced
+		pair.RegisteredAt = time.Now()
+		pair.TokenIssuedAt = pair.RegisteredAt
+		return false
+	}
+
+	policy := bot.getGuildUsosInfo(guildID).Reminders
+	delays := policy.delays()
+	if !policy.Enabled || pair.Unreminded || pair.Reminders >= len(delays) {
+		return false
+	}
+	due := pair.Reminders
+	for due < len(delays) && time.Since(pair.RegisteredAt) >= delays[due] {
+		due++
+	}
+	if due == pair.Reminders {
+		return false
+	}
+	// reminders missed e.g. when the bot was down are not sent all at once
+	pair.Reminders = due
+	return true
+}
+
+// remindPendingMember reminds the user about his pending verification on the guild with the given text,
+// or the default one if it is empty
+func (bot *UsosBot) remindPendingMember(userID string, guildID string, text string) error {
+	member, err := bot.guildMember(guildID, userID)
+	if err != nil {
+		if IsNotFound(err) {
+			bot.mu.Lock()
+			defer bot.mu.Unlock()
+			return bot.removeUnauthorizedUser(userID, guildID)
+		}
+		return err
+	}
+	guild, err := bot.Guild(guildID)
+	if err != nil {
+		return err
+	}
+
+	authorizationURL, err := bot.renewRequestToken(userID, guildID)
+	if err != nil {
+		return err
+	}
+
+	if text == "" {
+		text = fmt.Sprintf("Your verification on %s is still pending.", guild.Name)
+	}
+	bot.mu.Lock()
+	text += fmt.Sprintf(" Use %s to stop receiving reminders or %s to abort the verification.",
+		bot.commandSpan(guildID, "verify -q"), bot.commandSpan(guildID, "verify -a"))
+	bot.mu.Unlock()
+	return bot.sendAuthorizationInstructions(member, authorizationURL, text)
+}
@@ -0,0 +1,176 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Ogurczak/discord-usos-auth/usos"
+	"github.com/Ogurczak/discord-usos-auth/utils"
+	"github.com/bwmarrin/discordgo"
+)
+
+// minReverificationDelay is the minimal time between re-checks of a member
+const minReverificationDelay = 24 * time.Hour
+
+// reverificationPolicy represents a guild's settings of re-verifying members when their terms end
+type reverificationPolicy struct {
+	Enabled   bool
+	GraceDays int  // days members not qualifying anymore keep their roles
+	Notify    bool // notify members about their expiring verification
+}
+
+// reverifyMembers re-checks members whose verification expired at the end of their term.
+// Members with a linked identity are re-checked using fresh usos data, others are asked to verify again.
+// Members who do not qualify anymore lose their roles after the guild's grace period.
+func (bot *UsosBot) reverifyMembers(guildID string) error {
+	bot.mu.Lock()
+	guildInfo := bot.getGuildUsosInfo(guildID)
+	if !guildInfo.Reverification.Enabled {
+		bot.mu.Unlock()
+		return nil
+	}
+	grace := time.Duration(guildInfo.Reverification.GraceDays) * 24 * time.Hour
+	expiredAt := make(map[string]time.Time) // maps user id to the time his verification expired, zero if it has not yet
+	for userID, verification := range guildInfo.VerifiedMembers {
+		if verification.ExpiresAt.IsZero() || time.Now().Before(verification.ExpiresAt) {
+			continue
+		}
+		if guildInfo.Grants[userID] != nil {
+			// granted members keep their authorization regardless of usos
+			continue
+		}
+		expiredAt[userID] = verification.ExpiredAt
+	}
+	bot.mu.Unlock()
+
+	for userID, expired := range expiredAt {
+		member, err := bot.GuildMember(guildID, userID)
+		if err != nil {
+			if IsNotFound(err) {
+				bot.mu.Lock()
+				delete(bot.getGuildUsosInfo(guildID).VerifiedMembers, userID)
+				bot.mu.Unlock()
+				continue
+			}
+			// one member failing must not stop the others from being re-checked
+			log.Println(err)
+			continue
+		}
+		member.GuildID = guildID
+
+		if !expired.IsZero() {
+			if time.Now().After(expired.Add(grace)) {
+				err = bot.expireMember(member)
+				if err != nil {
+					log.Println(err)
+				}
+			}
+			continue
+		}
+
+		err = bot.reverifyMember(member)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	return nil
+}
+
+// reverifyMember re-checks the member with his linked identity, or asks him to verify again if he has none
+func (bot *UsosBot) reverifyMember(member *discordgo.Member) error {
+	usosUser, err := bot.refreshIdentity(member.User.ID)
+	switch err.(type) {
+	case nil:
+		transitioned, err := bot.applyLifecycle(member, usosUser)
+		if err != nil || transitioned {
+			return err
+		}
+		bot.mu.Lock()
+		match, err := bot.filter(member.GuildID, usosUser)
+		bot.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		if match {
+			err = bot.authorizeMember(member, usosUser)
+			if err != nil {
+				return err
+			}
+			bot.mu.Lock()
+			bot.recordVerification(member.GuildID, member.User.ID, usosUser)
+			bot.mu.Unlock()
+			return nil
+		}
+		bot.mu.Lock()
+		bot.markExpired(member.GuildID, member.User.ID)
+		notice := bot.graceNotice(member.GuildID)
+		bot.mu.Unlock()
+		return bot.notifyExpiry(member, fmt.Sprintf("<@%s> does not meet the requirements anymore", member.User.ID),
+			"You do not meet this server's requirements anymore. "+notice)
+	case *ErrIdentityNotLinked, *usos.ErrUnableToCall, *usos.ErrHTTP:
+		bot.mu.Lock()
+		bot.markExpired(member.GuildID, member.User.ID)
+		notice := bot.graceNotice(member.GuildID)
+		bot.mu.Unlock()
+		description := fmt.Sprintf("Your verification has expired with the end of the term. %s", notice)
+		err = bot.addUnauthorizedMember(member, description)
+		switch err.(type) {
+		case nil, *ErrAlreadyRegistered:
+			// no-op
+		default:
+			log.Println(err)
+		}
+		return bot.logDiscord(member.GuildID, fmt.Sprintf("<@%s> was asked to verify again", member.User.ID))
+	default:
+		return err
+	}
+}
+
+// markExpired records that the member's verification has expired now, starting his grace period
+func (bot *UsosBot) markExpired(guildID string, userID string) {
+	verification := bot.getGuildUsosInfo(guildID).VerifiedMembers[userID]
+	if verification != nil {
+		verification.ExpiredAt = time.Now()
+	}
+}
+
+// expireMember takes away the roles of a member whose grace period has passed
+func (bot *UsosBot) expireMember(member *discordgo.Member) error {
+	err := bot.deauthorizeMember(member.GuildID, member.User.ID)
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+	return bot.notifyExpiry(member, fmt.Sprintf("<@%s>'s verification has expired, roles removed", member.User.ID),
+		"Your verification has expired and your roles were removed. "+
+			"Click the button on the server's authorization message to verify again.")
+}
+
+// notifyExpiry logs the message to the guild's log channels and, if the guild wishes so,
+// sends the private message to the member
+func (bot *UsosBot) notifyExpiry(member *discordgo.Member, logMsg string, privMsg string) error {
+	err := bot.logDiscord(member.GuildID, logMsg)
+	if err != nil {
+		return err
+	}
+	bot.mu.Lock()
+	notify := bot.getGuildUsosInfo(member.GuildID).Reverification.Notify
+	bot.mu.Unlock()
+	if !notify {
+		return nil
+	}
+	guild, err := bot.Guild(member.GuildID)
+	if err != nil {
+		return err
+	}
+	return bot.msgMemberText(member, fmt.Sprintf("%s: %s", utils.DiscordBold(guild.Name), privMsg))
+}
+
+// graceNotice describes the guild's grace period to the member
+func (bot *UsosBot) graceNotice(guildID string) string {
+	graceDays := bot.getGuildUsosInfo(guildID).Reverification.GraceDays
+	if graceDays == 0 {
+		return ""
+	}
+	return fmt.Sprintf("You keep your roles for %d days after expiry.", graceDays)
+}
@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Ogurczak/discord-usos-auth/usos"
+	"github.com/Ogurczak/discord-usos-auth/utils"
+)
+
+// roleRule represents a rule giving a role to authorized members whose usos data pass its filter
+type roleRule struct {
+	RoleID string
+	Filter *usos.User
+}
+
+// newUsosFilter builds an usos user filter out of command arguments,
+// groups are expected in the <course id>:<group number> format
+func newUsosFilter(programmes []string, courses []string, terms []string, groups []string, staffStatus int) (*usos.User, error) {
+	usosProrammes := make([]*usos.Programme, len(programmes))
+	for i, programme := range programmes {
+		usosProrammes[i] = &usos.Programme{Name: programme}
+	}
+	usosCourses := make([]*usos.Course, 0, len(courses)+len(terms))
+	for _, course := range courses {
+		usosCourses = append(usosCourses, &usos.Course{ID: course})
+	}
+	for _, term := range terms {
+		usosCourses = append(usosCourses, &usos.Course{TermID: term})
+	}
+	usosGroups := make([]*usos.Group, len(groups))
+	for i, group := range groups {
+		sep := strings.LastIndex(group, ":")
+		if sep == -1 {
+			return nil, newErrGroupFormat(group)
+		}
+		number, err := strconv.Atoi(group[sep+1:])
+		if err != nil {
+			return nil, newErrGroupFormat(group)
+		}
+		usosGroups[i] = &usos.Group{CourseID: group[:sep], Number: number}
+	}
+
+	filter := &usos.User{
+		StaffStatus: staffStatus,
+		Programmes:  usosProrammes,
+		Courses:     usosCourses,
+		Groups:      usosGroups,
+	}
+	if len(usosProrammes) == 0 && len(usosCourses) == 0 && len(usosGroups) == 0 && staffStatus == usos.StaffStatusNone {
+		return nil, newErrFilterEmpty()
+	}
+	return filter, nil
+}
+
+// ruleRoles splits the ids of roles given by the guild's role rules
+// into those matching the usos user and those which do not
+func (bot *UsosBot) ruleRoles(guildID string, usosUser *usos.User) (matched map[string]bool, unmatched map[string]bool, err error) {
+	matched = make(map[string]bool)
+	unmatched = make(map[string]bool)
+	for _, rule := range bot.getGuildUsosInfo(guildID).RoleRules {
+		match, err := utils.FilterRec(rule.Filter, usosUser)
+		if err != nil {
+			return nil, nil, err
+		}
+		if match {
+			matched[rule.RoleID] = true
+		} else {
+			unmatched[rule.RoleID] = true
+		}
+	}
+	// a role matched by any of its rules is kept
+	for roleID := range matched {
+		delete(unmatched, roleID)
+	}
+	return matched, unmatched, nil
+}
+
+// removeRoleRules removes all role rules giving the given role
+func (bot *UsosBot) removeRoleRules(guildID string, roleID string) {
+	guildInfo := bot.getGuildUsosInfo(guildID)
+	rules := guildInfo.RoleRules[:0]
+	for _, rule := range guildInfo.RoleRules {
+		if rule.RoleID != roleID {
+			rules = append(rules, rule)
+		}
+	}
+	guildInfo.RoleRules = rules
+}
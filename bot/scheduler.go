@@ -0,0 +1,77 @@
+package bot
+
+import (
+	"log"
+	"time"
+)
+
+// schedulerInterval is the interval between runs of the bot's scheduled jobs
+const schedulerInterval = time.Hour
+
+// scheduledJobs returns jobs run periodically on every guild
+func (bot *UsosBot) scheduledJobs() []func(guildID string) error {
+	return []func(guildID string) error{
+		bot.archiveEndedCourses,
+		bot.reverifyMembers,
+		bot.removeAlumni,
+		bot.sweepUnverified,
+	}
+}
+
+// Open opens the discord session and starts the bot's scheduled jobs
+func (bot *UsosBot) Open() error {
+	err := bot.Session.Open()
+	if err != nil {
+		return err
+	}
+	bot.schedulerStop = make(chan struct{})
+	go bot.runScheduler(bot.schedulerStop)
+	return nil
+}
+
+// Close stops the bot's scheduled jobs and closes the discord session
+func (bot *UsosBot) Close() error {
+	if bot.schedulerStop != nil {
+		close(bot.schedulerStop)
+		bot.schedulerStop = nil
+	}
+	return bot.Session.Close()
+}
+
+// runScheduler runs the scheduled jobs every schedulerInterval
+// and sends reminders every reminderInterval until stopped
+func (bot *UsosBot) runScheduler(stop chan struct{}) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+	reminderTicker := time.NewTicker(reminderInterval)
+	defer reminderTicker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			bot.runScheduledJobs()
+		case <-reminderTicker.C:
+			bot.remindPending()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// runScheduledJobs runs all the scheduled jobs on every guild
+func (bot *UsosBot) runScheduledJobs() {
+	bot.mu.Lock()
+	guildIDs := make([]string, 0, len(bot.guildUsosInfos))
+	for guildID := range bot.guildUsosInfos {
+		guildIDs = append(guildIDs, guildID)
+	}
+	bot.mu.Unlock()
+
+	for _, guildID := range guildIDs {
+		for _, job := range bot.scheduledJobs() {
+			err := job(guildID)
+			if err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
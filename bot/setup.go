@@ -0,0 +1,229 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// setupPlanLifetime is the time after which unconfirmed setup plans are discarded
+const setupPlanLifetime = 10 * time.Minute
+
+// gatePlan represents changes to be made to a guild in order to gate its channels behind the authorize role
+type gatePlan struct {
+	WelcomeChannelID string   // channel @everyone can only read and get authorized in
+	CategoryIDs      []string // categories only the authorize role can view
+	RoleID           string   // role to take over as the authorize role, empty to keep the current one or create it
+	Prompt           string   // prompt on the authorize message posted in the welcome channel
+	CreatedAt        time.Time
+}
+
+// planGate validates the gate's channels and role and stores a plan of the gate
+// awaiting confirmation, replacing the guild's previous plan
+func (bot *UsosBot) planGate(guildID string, welcomeChannelID string, categoryIDs []string,
+	roleID string, prompt string) (*gatePlan, error) {
+	channel, err := bot.Channel(welcomeChannelID)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, newErrChannelNotFound(err, welcomeChannelID)
+		}
+		return nil, err
+	}
+	if channel.GuildID != guildID || channel.Type != discordgo.ChannelTypeGuildText {
+		return nil, newErrChannelNotFound(nil, welcomeChannelID)
+	}
+	for _, categoryID := range categoryIDs {
+		_, err = bot.guildCategory(guildID, categoryID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if roleID != "" {
+		_, err = bot.guildRole(guildID, roleID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	plan := &gatePlan{
+		WelcomeChannelID: welcomeChannelID,
+		CategoryIDs:      categoryIDs,
+		RoleID:           roleID,
+		Prompt:           prompt,
+		CreatedAt:        time.Now(),
+	}
+	bot.mu.Lock()
+	bot.setupPlans[guildID] = plan
+	bot.mu.Unlock()
+	return plan, nil
+}
+
+// pendingGatePlan returns the guild's plan awaiting confirmation
+func (bot *UsosBot) pendingGatePlan(guildID string) (*gatePlan, error) {
+	plan := bot.setupPlans[guildID]
+	if plan == nil || time.Since(plan.CreatedAt) > setupPlanLifetime {
+		delete(bot.setupPlans, guildID)
+		return nil, newErrSetupNotFound(guildID)
+	}
+	return plan, nil
+}
+
+// describeGatePlan returns a human readable preview of the changes the plan makes to the guild's channels
+func (bot *UsosBot) describeGatePlan(guildID string, plan *gatePlan, channels []*discordgo.Channel) string {
+	var b strings.Builder
+	b.WriteString("The following changes will be made:\n")
+	switch {
+	case plan.RoleID != "":
+		fmt.Fprintf(&b, "- <@&%s> becomes the authorize role\n", plan.RoleID)
+	case bot.getGuildUsosInfo(guildID).AuthorizeRoleID != "":
+		fmt.Fprintf(&b, "- <@&%s> stays the authorize role\n", bot.getGuildUsosInfo(guildID).AuthorizeRoleID)
+	default:
+		b.WriteString("- a new authorize role is created\n")
+	}
+	fmt.Fprintf(&b, "- @everyone can read but not write in <#%s>\n", plan.WelcomeChannelID)
+	if len(plan.CategoryIDs) == 0 {
+		b.WriteString("- only the authorize role can view all other channels\n")
+	}
+	for _, categoryID := range plan.CategoryIDs {
+		fmt.Fprintf(&b, "- only the authorize role can view <#%s> and its channels\n", categoryID)
+	}
+	if len(plan.CategoryIDs) > 0 {
+		b.WriteString("- channels outside of these categories stay open to @everyone\n")
+	}
+	gated := make(map[string]bool)
+	for _, channelID := range gatedChannelIDs(plan, channels) {
+		gated[channelID] = true
+	}
+	for _, channelID := range bot.logChannelIDs(guildID) {
+		if gated[channelID] {
+			fmt.Fprintf(&b, "- log channel <#%s> is gated as well\n", channelID)
+		}
+	}
+	if fallbackChannelID := bot.getGuildUsosInfo(guildID).DMFallbackChannelID; gated[fallbackChannelID] {
+		fmt.Fprintf(&b, "- fallback channel <#%s> is gated as well, "+
+			"unverified members not accepting private messages cannot be reached there anymore\n", fallbackChannelID)
+	}
+	fmt.Fprintf(&b, "- an authorize message is posted in <#%s>\n", plan.WelcomeChannelID)
+	fmt.Fprintf(&b, "Confirm with %s or cancel with %s in %s.",
+		bot.commandSpan(guildID, "setup confirm"), bot.commandSpan(guildID, "setup cancel"), setupPlanLifetime)
+	return b.String()
+}
+
+// applyGatePlan makes the changes described by the plan
+func (bot *UsosBot) applyGatePlan(guildID string, plan *gatePlan) error {
+	var authorizeRole *discordgo.Role
+	var err error
+	if plan.RoleID != "" {
+		authorizeRole, err = bot.guildRole(guildID, plan.RoleID)
+		if err != nil {
+			return err
+		}
+		bot.mu.Lock()
+		bot.getGuildUsosInfo(guildID).AuthorizeRoleID = authorizeRole.ID
+		bot.mu.Unlock()
+	} else {
+		authorizeRole, err = bot.getAuthorizeRole(guildID)
+		if IsNotFound(err) {
+			authorizeRole, err = bot.createAuthorizeRole(guildID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	channels, err := bot.GuildChannels(guildID)
+	if err != nil {
+		return err
+	}
+	for _, overwrite := range gateOverwrites(guildID, plan, channels, authorizeRole.ID, bot.State.User.ID) {
+		err = bot.overwritePermissions(overwrite.ChannelID, overwrite.ID, overwrite.Type, overwrite.Allow, overwrite.Deny)
+		if err != nil {
+			return err
+		}
+	}
+
+	return bot.spawnAuthorizeMessage(guildID, plan.WelcomeChannelID, plan.Prompt)
+}
+
+// gateOverwrite is a permission overwrite of a role or a member on a channel
+type gateOverwrite struct {
+	ChannelID string
+	ID        string
+	Type      discordgo.PermissionOverwriteType
+	Allow     int64
+	Deny      int64
+}
+
+// gatedChannelIDs returns ids of the channels the plan hides from @everyone. All channels but the welcome channel
+// are gated, or only the plan's categories and their channels if it lists any.
+func gatedChannelIDs(plan *gatePlan, channels []*discordgo.Channel) []string {
+	categories := make(map[string]bool)
+	for _, categoryID := range plan.CategoryIDs {
+		categories[categoryID] = true
+	}
+	channelIDs := make([]string, 0)
+	for _, channel := range channels {
+		if channel.ID == plan.WelcomeChannelID {
+			continue
+		}
+		if len(categories) > 0 && !categories[channel.ID] && !categories[channel.ParentID] {
+			continue
+		}
+		channelIDs = append(channelIDs, channel.ID)
+	}
+	return channelIDs
+}
+
+// gateOverwrites returns the overwrites the plan applies to the guild's channels.
+// The bot keeps access to all of them so it can still post in the welcome channel and its log channels.
+func gateOverwrites(guildID string, plan *gatePlan, channels []*discordgo.Channel,
+	authorizeRoleID string, botUserID string) []gateOverwrite {
+	// @everyone role shares its id with the guild
+	overwrites := []gateOverwrite{
+		{
+			ChannelID: plan.WelcomeChannelID,
+			ID:        guildID,
+			Type:      discordgo.PermissionOverwriteTypeRole,
+			Allow:     discordgo.PermissionViewChannel | discordgo.PermissionReadMessageHistory | discordgo.PermissionAddReactions,
+			Deny: discordgo.PermissionSendMessages | discordgo.PermissionSendMessagesInThreads |
+				discordgo.PermissionCreatePublicThreads,
+		},
+		{
+			ChannelID: plan.WelcomeChannelID,
+			ID:        botUserID,
+			Type:      discordgo.PermissionOverwriteTypeMember,
+			Allow:     discordgo.PermissionViewChannel | discordgo.PermissionSendMessages,
+		},
+	}
+
+	for _, channelID := range gatedChannelIDs(plan, channels) {
+		overwrites = append(overwrites,
+			gateOverwrite{ChannelID: channelID, ID: guildID, Type: discordgo.PermissionOverwriteTypeRole,
+				Deny: discordgo.PermissionViewChannel},
+			gateOverwrite{ChannelID: channelID, ID: authorizeRoleID, Type: discordgo.PermissionOverwriteTypeRole,
+				Allow: discordgo.PermissionViewChannel},
+			gateOverwrite{ChannelID: channelID, ID: botUserID, Type: discordgo.PermissionOverwriteTypeMember,
+				Allow: discordgo.PermissionViewChannel})
+	}
+	return overwrites
+}
+
+// overwritePermissions allows and denies the permissions to the role or member on the channel,
+// keeping the other permissions of the current overwrite
+func (bot *UsosBot) overwritePermissions(channelID string, id string, overwriteType discordgo.PermissionOverwriteType,
+	allow int64, deny int64) error {
+	channel, err := bot.Channel(channelID)
+	if err != nil {
+		return err
+	}
+	for _, overwrite := range channel.PermissionOverwrites {
+		if overwrite.Type == overwriteType && overwrite.ID == id {
+			allow |= overwrite.Allow &^ deny
+			deny |= overwrite.Deny &^ allow
+			break
+		}
+	}
+	return bot.ChannelPermissionSet(channelID, id, overwriteType, allow, deny)
+}
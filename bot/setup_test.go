@@ -0,0 +1,92 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGateOverwrites(t *testing.T) {
+	channels := []*discordgo.Channel{
+		{ID: "welcomeID", ParentID: "lobbyID"},
+		{ID: "lobbyID", Type: discordgo.ChannelTypeGuildCategory},
+		{ID: "coursesID", Type: discordgo.ChannelTypeGuildCategory},
+		{ID: "courseID", ParentID: "coursesID"},
+		{ID: "generalID"},
+	}
+	welcome := []gateOverwrite{
+		{
+			ChannelID: "welcomeID",
+			ID:        "guildID",
+			Type:      discordgo.PermissionOverwriteTypeRole,
+			Allow:     discordgo.PermissionViewChannel | discordgo.PermissionReadMessageHistory | discordgo.PermissionAddReactions,
+			Deny: discordgo.PermissionSendMessages | discordgo.PermissionSendMessagesInThreads |
+				discordgo.PermissionCreatePublicThreads,
+		},
+		{
+			ChannelID: "welcomeID",
+			ID:        "botID",
+			Type:      discordgo.PermissionOverwriteTypeMember,
+			Allow:     discordgo.PermissionViewChannel | discordgo.PermissionSendMessages,
+		},
+	}
+	gate := func(channelID string) []gateOverwrite {
+		return []gateOverwrite{
+			{ChannelID: channelID, ID: "guildID", Type: discordgo.PermissionOverwriteTypeRole,
+				Deny: discordgo.PermissionViewChannel},
+			{ChannelID: channelID, ID: "authorizeRoleID", Type: discordgo.PermissionOverwriteTypeRole,
+				Allow: discordgo.PermissionViewChannel},
+			{ChannelID: channelID, ID: "botID", Type: discordgo.PermissionOverwriteTypeMember,
+				Allow: discordgo.PermissionViewChannel},
+		}
+	}
+	concat := func(parts ...[]gateOverwrite) []gateOverwrite {
+		overwrites := make([]gateOverwrite, 0)
+		for _, part := range parts {
+			overwrites = append(overwrites, part...)
+		}
+		return overwrites
+	}
+
+	tests := []struct {
+		name        string
+		categoryIDs []string
+		want        []gateOverwrite
+	}{
+		{"whole server", nil,
+			concat(welcome, gate("lobbyID"), gate("coursesID"), gate("courseID"), gate("generalID"))},
+		{"categories", []string{"coursesID"},
+			concat(welcome, gate("coursesID"), gate("courseID"))},
+		{"category of the welcome channel", []string{"lobbyID"},
+			concat(welcome, gate("lobbyID"))},
+	}
+	for _, test := range tests {
+		plan := &gatePlan{WelcomeChannelID: "welcomeID", CategoryIDs: test.categoryIDs}
+		assert.Equal(t, test.want, gateOverwrites("guildID", plan, channels, "authorizeRoleID", "botID"), test.name)
+	}
+}
+
+func TestDescribeGatePlanListsGatedLogChannels(t *testing.T) {
+	bot := &UsosBot{
+		guildUsosInfos: map[string]*guildUsosInfo{
+			"guildID": {
+				LogChannelIDs:       map[string]bool{"logID": true, "openLogID": true},
+				DMFallbackChannelID: "fallbackID",
+			},
+		},
+	}
+	channels := []*discordgo.Channel{
+		{ID: "welcomeID"},
+		{ID: "staffID", Type: discordgo.ChannelTypeGuildCategory},
+		{ID: "logID", ParentID: "staffID"},
+		{ID: "fallbackID", ParentID: "staffID"},
+		{ID: "openLogID"},
+	}
+	plan := &gatePlan{WelcomeChannelID: "welcomeID", CategoryIDs: []string{"staffID"}}
+
+	description := bot.describeGatePlan("guildID", plan, channels)
+	assert.Contains(t, description, "log channel <#logID> is gated")
+	assert.NotContains(t, description, "<#openLogID>")
+	assert.Contains(t, description, "fallback channel <#fallbackID> is gated")
+}
@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Ogurczak/discord-usos-auth/utils"
+)
+
+// userStatus describes the user's pending and finished verifications on all guilds
+func (bot *UsosBot) userStatus(userID string) (string, error) {
+	// names of the guilds and roles are fetched before the state is locked for the description
+	bot.mu.Lock()
+	guildIDs := make([]string, 0)
+	verifiedGuildIDs := make([]string, 0)
+	for guildID := range bot.tokenMap[userID] {
+		guildIDs = append(guildIDs, guildID)
+	}
+	for guildID, guildInfo := range bot.guildUsosInfos {
+		if guildInfo.Grants[userID] != nil {
+			guildIDs = append(guildIDs, guildID)
+		} else if guildInfo.VerifiedMembers[userID] != nil {
+			guildIDs = append(guildIDs, guildID)
+			verifiedGuildIDs = append(verifiedGuildIDs, guildID)
+		}
+	}
+	bot.mu.Unlock()
+
+	guildNames := make(map[string]string)
+	for _, guildID := range guildIDs {
+		guildNames[guildID] = bot.guildName(guildID)
+	}
+	roleNames := make(map[string]string)
+	for _, guildID := range verifiedGuildIDs {
+		roles, err := bot.GuildRoles(guildID)
+		if err != nil {
+			continue
+		}
+		for _, role := range roles {
+			roleNames[role.ID] = role.Name
+		}
+	}
+	guildName := func(guildID string) string {
+		if name, ok := guildNames[guildID]; ok {
+			return name
+		}
+		return guildID
+	}
+
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+
+	var b strings.Builder
+	if pairs := bot.tokenMap[userID]; len(pairs) > 0 {
+		b.WriteString(utils.DiscordBold("Pending verifications") + "\n")
+		for guildID, pair := range pairs {
+			fmt.Fprintf(&b, "- %s: ", guildName(guildID))
+			if !pair.RegisteredAt.IsZero() {
+				fmt.Fprintf(&b, "started %s, ", pair.RegisteredAt.Format(time.RFC1123))
+			}
+			if time.Since(pair.TokenIssuedAt) >= bot.requestTokenLifetime() {
+				b.WriteString("the authorization link has expired, start the verification again for a new one\n")
+			} else {
+				fmt.Fprintf(&b, "authorize on [this page](%s)\n", pair.RequestToken.AuthorizationURL)
+			}
+		}
+	}
+
+	verified := ""
+	for guildID, guildInfo := range bot.guildUsosInfos {
+		if grant := guildInfo.Grants[userID]; grant != nil {
+			verified += fmt.Sprintf("- %s: authorization granted manually %s\n",
+				guildName(guildID), grant.GrantedAt.Format(time.RFC1123))
+			continue
+		}
+		record := guildInfo.VerifiedMembers[userID]
+		if record == nil {
+			continue
+		}
+		details, err := bot.verificationDetails(guildID, userID, record, roleNames)
+		if err != nil {
+			return "", err
+		}
+		verified += fmt.Sprintf("- %s: %s\n", guildName(guildID), details)
+	}
+	if verified != "" {
+		b.WriteString(utils.DiscordBold("Verifications") + "\n" + verified)
+	}
+
+	if b.Len() == 0 {
+		return "You are neither verified nor pending verification on any server", nil
+	}
+	return b.String(), nil
+}
+
+// verificationDetails describes the user's verification on the guild: its time and, if he linked his usos identity,
+// the filter that let him through and the roles given by role rules, named after roleNames which maps role ids to their names
+func (bot *UsosBot) verificationDetails(guildID string, userID string, record *verification,
+	roleNames map[string]string) (string, error) {
+	details := "verified " + record.VerifiedAt.Format(time.RFC1123)
+	if !record.ExpiresAt.IsZero() {
+		details += ", valid until " + record.ExpiresAt.Format(time.RFC1123)
+	}
+	if record.Stage == stageAlumnus {
+		details += ", alumnus"
+	}
+	usosUser := bot.linkedUser(userID)
+	if usosUser == nil {
+		return details, nil
+	}
+
+	guildInfo := bot.getGuildUsosInfo(guildID)
+	if len(guildInfo.Filters) > 0 {
+		i, err := bot.matchingFilter(guildID, usosUser)
+		if err != nil {
+			return "", err
+		}
+		if i >= 0 {
+			details += fmt.Sprintf(", let through by filter %d", i+1)
+		} else {
+			details += ", not matching any filter anymore"
+		}
+	}
+
+	roles := make([]string, 0)
+	for i, rule := range guildInfo.RoleRules {
+		match, err := utils.FilterRec(rule.Filter, usosUser)
+		if err != nil {
+			return "", err
+		}
+		if !match {
+			continue
+		}
+		name, ok := roleNames[rule.RoleID]
+		if !ok {
+			name = rule.RoleID
+		}
+		roles = append(roles, fmt.Sprintf("%s (rule %d)", name, i+1))
+	}
+	if len(roles) > 0 {
+		details += ", roles given: " + strings.Join(roles, ", ")
+	}
+	return details, nil
+}
+
+// guildName returns the bold name of the guild or its ID if the name cannot be fetched
+func (bot *UsosBot) guildName(guildID string) string {
+	guild, err := bot.Guild(guildID)
+	if err != nil {
+		return guildID
+	}
+	return utils.DiscordBold(guild.Name)
+}
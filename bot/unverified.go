@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// unverifiedPolicy represents a guild's settings of handling members who stay unverified
+type unverifiedPolicy struct {
+	Enabled         bool
+	RemindAfterDays int             // days after joining unverified members are reminded, 0 disables reminders
+	KickAfterDays   int             // days after joining unverified members are kicked, 0 disables kicking
+	ExemptRoleIDs   map[string]bool // members with any of these roles are never reminded nor kicked
+	Reminded        map[string]bool // set of ids of members already reminded
+}
+
+// initialize makes sure the policy's collections are allocated
+func (policy *unverifiedPolicy) initialize() {
+	if policy.ExemptRoleIDs == nil {
+		policy.ExemptRoleIDs = make(map[string]bool)
+	}
+	if policy.Reminded == nil {
+		policy.Reminded = make(map[string]bool)
+	}
+}
+
+// guildMembers returns all members of the guild
+func (bot *UsosBot) guildMembers(guildID string) ([]*discordgo.Member, error) {
+	members := make([]*discordgo.Member, 0)
+	after := ""
+	for {
+		page, err := bot.GuildMembers(guildID, after, 1000)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range page {
+			member.GuildID = guildID
+		}
+		members = append(members, page...)
+		if len(page) < 1000 {
+			return members, nil
+		}
+		after = page[len(page)-1].User.ID
+	}
+}
+
+// unverifiedAction is what the guild's unverified policy does to a member during a sweep
+type unverifiedAction int
+
+const (
+	unverifiedIgnore unverifiedAction = iota
+	unverifiedRemind
+	unverifiedKick
+)
+
+// unverifiedActionFor decides what to do with the member during a sweep of the guild,
+// members verified, granted authorization, holding the authorize or alumni role or an exempt role are ignored
+func unverifiedActionFor(guildInfo *guildUsosInfo, member *discordgo.Member, authorizeRoleID string,
+	now time.Time) unverifiedAction {
+	policy := guildInfo.UnverifiedPolicy
+	if member.User.Bot || guildInfo.VerifiedMembers[member.User.ID] != nil || guildInfo.Grants[member.User.ID] != nil {
+		return unverifiedIgnore
+	}
+	for _, roleID := range member.Roles {
+		if roleID == authorizeRoleID || policy.ExemptRoleIDs[roleID] ||
+			guildInfo.Lifecycle.AlumniRoleID != "" && roleID == guildInfo.Lifecycle.AlumniRoleID {
+			return unverifiedIgnore
+		}
+	}
+
+	age := now.Sub(member.JoinedAt)
+	switch {
+	case policy.KickAfterDays > 0 && age > time.Duration(policy.KickAfterDays)*24*time.Hour:
+		return unverifiedKick
+	case policy.RemindAfterDays > 0 && age > time.Duration(policy.RemindAfterDays)*24*time.Hour:
+		return unverifiedRemind
+	default:
+		return unverifiedIgnore
+	}
+}
+
+// sweepUnverified reminds members who stay unverified to verify and kicks those
+// who have not verified in the time the guild allows
+func (bot *UsosBot) sweepUnverified(guildID string) error {
+	bot.mu.Lock()
+	policy := bot.getGuildUsosInfo(guildID).UnverifiedPolicy
+	bot.mu.Unlock()
+	if !policy.Enabled {
+		return nil
+	}
+	authorizeRole, err := bot.getAuthorizeRole(guildID)
+	if err != nil {
+		if IsNotFound(err) {
+			// everybody would be kicked
+			return nil
+		}
+		return err
+	}
+
+	members, err := bot.guildMembers(guildID)
+	if err != nil {
+		return err
+	}
+	guild, err := bot.Guild(guildID)
+	if err != nil {
+		return err
+	}
+
+	bot.mu.Lock()
+	guildInfo := bot.getGuildUsosInfo(guildID)
+	now := time.Now()
+	alreadyReminded := make(map[string]bool)
+	for userID := range guildInfo.UnverifiedPolicy.Reminded {
+		alreadyReminded[userID] = true
+	}
+	actions := make(map[*discordgo.Member]unverifiedAction)
+	for _, member := range members {
+		if member.User.ID != guild.OwnerID {
+			actions[member] = unverifiedActionFor(guildInfo, member, authorizeRole.ID, now)
+		}
+	}
+	bot.mu.Unlock()
+
+	reminded := make(map[string]bool) // members who failed to be reminded are reminded again by the next sweep
+	var remindedNow, notReminded, kicked []string
+	for _, member := range members {
+		switch actions[member] {
+		case unverifiedKick:
+			err = bot.GuildMemberDeleteWithReason(guildID, member.User.ID,
+				fmt.Sprintf("Not verified with usos for %d days", policy.KickAfterDays))
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			kicked = append(kicked, fmt.Sprintf("<@%s>", member.User.ID))
+		case unverifiedRemind:
+			if alreadyReminded[member.User.ID] {
+				reminded[member.User.ID] = true
+				continue
+			}
+			err = bot.remindUnverified(member, guild.Name)
+			if err != nil {
+				log.Println(err)
+				notReminded = append(notReminded, fmt.Sprintf("<@%s>", member.User.ID))
+				continue
+			}
+			reminded[member.User.ID] = true
+			remindedNow = append(remindedNow, fmt.Sprintf("<@%s>", member.User.ID))
+		}
+	}
+	bot.mu.Lock()
+	bot.getGuildUsosInfo(guildID).UnverifiedPolicy.Reminded = reminded // members who verified or left are forgotten
+	bot.mu.Unlock()
+
+	if len(remindedNow) > 0 {
+		err = bot.logDiscord(guildID, "Reminded unverified members: "+strings.Join(remindedNow, ", "))
+		if err != nil {
+			return err
+		}
+	}
+	if len(notReminded) > 0 {
+		err = bot.logDiscord(guildID, "Could not remind unverified members: "+strings.Join(notReminded, ", "))
+		if err != nil {
+			return err
+		}
+	}
+	if len(kicked) > 0 {
+		err = bot.logDiscord(guildID, "Kicked unverified members: "+strings.Join(kicked, ", "))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remindUnverified reminds the member to verify, starting his authorization if he has not started it yet
+func (bot *UsosBot) remindUnverified(member *discordgo.Member, guildName string) error {
+	bot.mu.Lock()
+	kickAfterDays := bot.getGuildUsosInfo(member.GuildID).UnverifiedPolicy.KickAfterDays
+	pending := bot.tokenMap[member.User.ID][member.GuildID] != nil
+	verifyUsage := bot.commandSpan(member.GuildID, "verify -c <verifier>")
+	bot.mu.Unlock()
+
+	reminder := fmt.Sprintf("You have not verified on %s yet.", guildName)
+	if kickAfterDays > 0 {
+		reminder += fmt.Sprintf(" Members who do not verify in %d days since joining are kicked.", kickAfterDays)
+	}
+	if pending {
+		return bot.msgMemberText(member, reminder+" Finish your pending verification with the "+
+			verifyUsage+" command.")
+	}
+	return bot.startAuthorization(member, reminder)
+}
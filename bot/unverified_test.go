@@ -0,0 +1,57 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnverifiedActionFor(t *testing.T) {
+	now := time.Date(2021, 10, 10, 12, 0, 0, 0, time.UTC)
+	guildInfo := &guildUsosInfo{
+		UnverifiedPolicy: unverifiedPolicy{
+			Enabled:         true,
+			RemindAfterDays: 3,
+			KickAfterDays:   7,
+			ExemptRoleIDs:   map[string]bool{"guestRoleID": true},
+		},
+		Lifecycle:       lifecyclePolicy{Enabled: true, AlumniRoleID: "alumniRoleID"},
+		VerifiedMembers: map[string]*verification{"verifiedID": {UsosID: "usosID"}},
+		Grants:          map[string]*grant{"grantedID": {GrantedBy: "adminID"}},
+	}
+
+	tests := []struct {
+		name     string
+		userID   string
+		bot      bool
+		roles    []string
+		joinedAt time.Time
+		want     unverifiedAction
+	}{
+		{"new member", "userID", false, nil, now.Add(-24 * time.Hour), unverifiedIgnore},
+		{"remind", "userID", false, nil, now.Add(-4 * 24 * time.Hour), unverifiedRemind},
+		{"kick", "userID", false, nil, now.Add(-8 * 24 * time.Hour), unverifiedKick},
+		{"bot", "userID", true, nil, now.Add(-8 * 24 * time.Hour), unverifiedIgnore},
+		{"verified", "verifiedID", false, nil, now.Add(-8 * 24 * time.Hour), unverifiedIgnore},
+		{"granted", "grantedID", false, nil, now.Add(-8 * 24 * time.Hour), unverifiedIgnore},
+		{"authorize role", "userID", false, []string{"authorizeRoleID"}, now.Add(-8 * 24 * time.Hour), unverifiedIgnore},
+		{"exempt role", "userID", false, []string{"guestRoleID"}, now.Add(-8 * 24 * time.Hour), unverifiedIgnore},
+		{"alumnus", "userID", false, []string{"alumniRoleID"}, now.Add(-8 * 24 * time.Hour), unverifiedIgnore},
+		{"other role", "userID", false, []string{"otherRoleID"}, now.Add(-8 * 24 * time.Hour), unverifiedKick},
+	}
+	for _, test := range tests {
+		member := &discordgo.Member{
+			User:     &discordgo.User{ID: test.userID, Bot: test.bot},
+			Roles:    test.roles,
+			JoinedAt: test.joinedAt,
+		}
+		assert.Equal(t, test.want, unverifiedActionFor(guildInfo, member, "authorizeRoleID", now), test.name)
+	}
+
+	guildInfo.UnverifiedPolicy.KickAfterDays = 0
+	member := &discordgo.Member{User: &discordgo.User{ID: "userID"}, JoinedAt: now.Add(-8 * 24 * time.Hour)}
+	assert.Equal(t, unverifiedRemind, unverifiedActionFor(guildInfo, member, "authorizeRoleID", now),
+		"kicking disabled")
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/Ogurczak/discord-usos-auth/bot"
 	"github.com/akamensky/argparse"
@@ -16,6 +17,7 @@ var programmeName *string
 var botToken *string
 var settingsFilename *string
 var force *bool
+var requestTokenLifetime *int
 
 func init() {
 	parser := argparse.NewParser("discord-usos-auth", "Runs an Usos Authorization Bot instance using the given bot token")
@@ -24,6 +26,8 @@ func init() {
 		Help: "settings filepath, if not specified no settings will be saved nor loaded"})
 	force = parser.Flag("f", "force", &argparse.Options{Required: false,
 		Help: "do not ask to overwrite the settings file on exit"})
+	requestTokenLifetime = parser.Int("l", "request-token-lifetime", &argparse.Options{Required: false,
+		Help: "minutes after which usos request tokens are renewed, 0 for the default of 60", Default: 0})
 	err := parser.Parse(os.Args)
 	if err != nil {
 		log.Fatal(err)
@@ -61,6 +65,7 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	b.RequestTokenLifetime = time.Duration(*requestTokenLifetime) * time.Minute
 
 	if *settingsFilename == "" {
 		log.Println("No settings file specified, no settings will be saved upon exit")
@@ -124,7 +129,7 @@ func exitFunc(b *bot.UsosBot) func() {
 				}
 			}
 		}
-		file, err := os.OpenFile(*settingsFilename, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0755)
+		file, err := os.OpenFile(*settingsFilename, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0600) // contains usos data of verified users
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -12,11 +12,13 @@ import (
 func parseUserResponse(body io.Reader) (*User, error) {
 
 	var respUser struct {
-		ID         string `json:"id"`
-		FirstName  string `json:"first_name"`
-		LastName   string `json:"last_name"`
-		Programmes []struct {
+		ID          string `json:"id"`
+		FirstName   string `json:"first_name"`
+		LastName    string `json:"last_name"`
+		StaffStatus int    `json:"staff_status"`
+		Programmes  []struct {
 			ID        string `json:"id"`
+			Status    string `json:"status"`
 			Programme struct {
 				Name        string `json:"id"`
 				Description struct {
@@ -42,13 +44,15 @@ func parseUserResponse(body io.Reader) (*User, error) {
 			ID:          respProg.ID,
 			Name:        respProg.Programme.Name,
 			Description: respProg.Programme.Description.PL,
+			Status:      respProg.Status,
 		}
 	}
 	return &User{
-		ID:         respUser.ID,
-		FirstName:  respUser.FirstName,
-		LastName:   respUser.LastName,
-		Programmes: progs,
+		ID:          respUser.ID,
+		FirstName:   respUser.FirstName,
+		LastName:    respUser.LastName,
+		StaffStatus: respUser.StaffStatus,
+		Programmes:  progs,
 	}, nil
 }
 
@@ -90,25 +94,25 @@ func parseCoursesResponse(activeOnly bool, resp io.Reader) ([]*Course, error) {
 	return courses, nil
 }
 
-func parseGroupsResponseToCourses(activeOnly bool, resp io.Reader) ([]*Course, error) {
+func parseGroupsResponse(activeOnly bool, resp io.Reader) ([]*Course, []*Group, []*Term, error) {
 	jParsed := make(map[string]interface{})
 	dat, err := ioutil.ReadAll(resp)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	err = json.Unmarshal(dat, &jParsed)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	terms := make(map[string]*Term)
 	decoderTerm, err := termDecoder(&terms)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	err = decoderTerm.Decode(jParsed["terms"])
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	courseHookFunc := mapstructure.ComposeDecodeHookFunc(
@@ -122,11 +126,11 @@ func parseGroupsResponseToCourses(activeOnly bool, resp io.Reader) ([]*Course, e
 		Result:     &courses,
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	err = decoderCourse.Decode(jParsed["groups"])
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	// now convert back to slice
@@ -137,7 +141,28 @@ func parseGroupsResponseToCourses(activeOnly bool, resp io.Reader) ([]*Course, e
 		i++
 	}
 
-	return coursesSlice, nil
+	groupHookFunc := mapstructure.ComposeDecodeHookFunc(
+		editionsActiveHookFunc(activeOnly, terms),
+		multilangToPLHookFunc)
+	groups := make([]*Group, 0)
+	decoderGroup, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: groupHookFunc,
+		Result:     &groups,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	err = decoderGroup.Decode(jParsed["groups"])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	termsSlice := make([]*Term, 0, len(terms))
+	for _, term := range terms {
+		termsSlice = append(termsSlice, term)
+	}
+
+	return coursesSlice, groups, termsSlice, nil
 }
 
 func termDecoder(terms *map[string]*Term) (*mapstructure.Decoder, error) {
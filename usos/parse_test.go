@@ -0,0 +1,76 @@
+package usos
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var groupsResponse = `{
+	"groups": {
+		"2020Z": [
+			{"course_id": "103A-INxxx-ISP-ANMA", "term_id": "2020Z",
+				"course_name": {"pl": "Analiza", "en": "Calculus"}, "group_number": 1, "class_type_id": "WYK"},
+			{"course_id": "103A-INxxx-ISP-ANMA", "term_id": "2020Z",
+				"course_name": {"pl": "Analiza", "en": "Calculus"}, "group_number": 12, "class_type_id": "CWI"}
+		]
+	},
+	"terms": [
+		{"id": "2020Z", "name": {"pl": "Semestr zimowy 2020/21", "en": "Winter semester 2020/21"},
+			"start_date": "2020-10-01", "end_date": "2021-02-21", "finish_date": "2021-03-07"}
+	]
+}`
+
+func TestParseGroupsResponse(t *testing.T) {
+	courses, groups, terms, err := parseGroupsResponse(false, strings.NewReader(groupsResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.ElementsMatch(t, courses, []*Course{
+		{ID: "103A-INxxx-ISP-ANMA", Name: "Analiza", TermID: "2020Z"},
+	})
+	assert.ElementsMatch(t, groups, []*Group{
+		{CourseID: "103A-INxxx-ISP-ANMA", Number: 1, ClassType: "WYK"},
+		{CourseID: "103A-INxxx-ISP-ANMA", Number: 12, ClassType: "CWI"},
+	})
+	assert.ElementsMatch(t, terms, []*Term{
+		{ID: "2020Z", Name: "Semestr zimowy 2020/21",
+			StartDate:  time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:    time.Date(2021, 2, 21, 0, 0, 0, 0, time.UTC),
+			FinishDate: time.Date(2021, 3, 7, 0, 0, 0, 0, time.UTC)},
+	})
+}
+
+var userResponse = `{
+	"id": "123123",
+	"first_name": "Witold",
+	"last_name": "Wysota",
+	"staff_status": 0,
+	"student_programmes": [
+		{"id": "1", "status": "graduated_end_of_study",
+			"programme": {"id": "103C-ISP-IN", "description": {"pl": "Informatyka", "en": "Computer Science"}}},
+		{"id": "2", "status": "cancelled",
+			"programme": {"id": "103C-ISP-MA", "description": {"pl": "Matematyka", "en": "Mathematics"}}}
+	]
+}`
+
+func TestParseUserResponse(t *testing.T) {
+	user, err := parseUserResponse(strings.NewReader(userResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, &User{
+		ID:        "123123",
+		FirstName: "Witold",
+		LastName:  "Wysota",
+		Programmes: []*Programme{
+			{ID: "1", Name: "103C-ISP-IN", Description: "Informatyka", Status: ProgrammeStatusGraduatedEndOfStudy},
+			{ID: "2", Name: "103C-ISP-MA", Description: "Matematyka", Status: ProgrammeStatusCancelled},
+		},
+	}, user)
+	assert.True(t, user.IsAlumnus())
+}
@@ -9,11 +9,21 @@ import (
 
 // Term represents an usos term
 type Term struct {
-	ID         string    `mapstructure:"id"`
-	Name       string    `mapstructure:"name"`
-	StartDate  time.Time `mapstructure:"start_date"`
-	EndDate    time.Time `mapstructure:"end_date"`
-	FinishDate time.Time `mapstructure:"finish_date"`
+	ID         string    `json:"id,omitempty" mapstructure:"id"`
+	Name       string    `json:"name,omitempty" mapstructure:"name"`
+	StartDate  time.Time `json:"start_date,omitempty" mapstructure:"start_date"`
+	EndDate    time.Time `json:"end_date,omitempty" mapstructure:"end_date"`
+	FinishDate time.Time `json:"finish_date,omitempty" mapstructure:"finish_date"`
+}
+
+// Term returns the user's term with the given ID or nil if there is none
+func (u *User) Term(termID string) *Term {
+	for _, term := range u.Terms {
+		if term.ID == termID {
+			return term
+		}
+	}
+	return nil
 }
 
 // IsActive checks if the term is active
@@ -29,29 +39,74 @@ type Course struct {
 	TermID string `json:"term_id,omitempty" mapstructure:"term_id"`
 }
 
+// Group represents an usos class group the user attends
+type Group struct {
+	CourseID  string `json:"course_id,omitempty" mapstructure:"course_id"`
+	Number    int    `json:"group_number,omitempty" mapstructure:"group_number"`
+	ClassType string `json:"class_type_id,omitempty" mapstructure:"class_type_id"`
+}
+
 // Programme represents an usos student programme
 type Programme struct {
 	ID          string `json:"id,omitempty"`
 	Name        string `json:"name,omitempty"`
 	Description string `json:"description,omitempty"`
+	Status      string `json:"status,omitempty"`
+}
+
+// Statuses of an usos student programme
+const (
+	ProgrammeStatusActive                 = "active"
+	ProgrammeStatusCancelled              = "cancelled"
+	ProgrammeStatusGraduatedBeforeDiploma = "graduated_before_diploma"
+	ProgrammeStatusGraduatedEndOfStudy    = "graduated_end_of_study"
+)
+
+// IsGraduated checks if the student has graduated from the programme
+func (p *Programme) IsGraduated() bool {
+	return p.Status == ProgrammeStatusGraduatedBeforeDiploma || p.Status == ProgrammeStatusGraduatedEndOfStudy
 }
 
+// Staff statuses of an usos user
+const (
+	StaffStatusNone     = 0
+	StaffStatusEmployee = 1
+	StaffStatusTeacher  = 2
+)
+
 // User represents an usos user
 type User struct {
-	ID         string       `json:"id,omitempty"`
-	FirstName  string       `json:"first_name,omitempty"`
-	LastName   string       `json:"last_name,omitempty"`
-	Programmes []*Programme `json:"student_programmes,omitempty"`
-	Courses    []*Course    `json:"student_courses,omitempty"`
+	ID          string       `json:"id,omitempty"`
+	FirstName   string       `json:"first_name,omitempty"`
+	LastName    string       `json:"last_name,omitempty"`
+	StaffStatus int          `json:"staff_status,omitempty"`
+	Programmes  []*Programme `json:"student_programmes,omitempty"`
+	Courses     []*Course    `json:"student_courses,omitempty"`
+	Groups      []*Group     `json:"student_groups,omitempty"`
+	Terms       []*Term      `json:"terms,omitempty"`
 
 	token *oauth1.Token
 }
 
+// IsAlumnus checks if the user has graduated from at least one programme and does not study any other
+func (u *User) IsAlumnus() bool {
+	graduated := false
+	for _, programme := range u.Programmes {
+		if programme.Status == ProgrammeStatusActive {
+			return false
+		}
+		if programme.IsGraduated() {
+			graduated = true
+		}
+	}
+	return graduated
+}
+
 // NewUsosUser returns an UsosUser object initialized from api calls using the given access token
 func NewUsosUser(token *oauth1.Token) (*User, error) {
 	client := config.Client(oauth1.NoContext, token)
 
-	resp, err := makeCall(client, "user", "id|first_name|last_name|student_programmes")
+	resp, err := makeCall(client, "user", "id|first_name|last_name|staff_status|student_programmes[id|programme|status]")
 	if err != nil {
 		return nil, err
 	}
@@ -88,21 +143,23 @@ func (u *User) GetCourses(activeOnly bool) ([]*Course, error) {
 }
 
 // GetCoursesLight returns and assigns his currently active courses to the user,
-// does not download unneeded information
+// does not download unneeded information. Assigns the user's class groups and their terms as well.
 func (u *User) GetCoursesLight(activeOnly bool) ([]*Course, error) {
 	client := u.client()
-	resp, err := makeCall(client, "groups", "course_id|term_id|course_name", activeOnly)
+	resp, err := makeCall(client, "groups", "course_id|term_id|course_name|group_number|class_type_id", activeOnly)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Close()
 
-	courses, err := parseGroupsResponseToCourses(activeOnly, resp)
+	courses, groups, terms, err := parseGroupsResponse(activeOnly, resp)
 	if err != nil {
 		return nil, err
 	}
 
 	u.Courses = courses
+	u.Groups = groups
+	u.Terms = terms
 	// dat, err := ioutil.ReadAll(resp)
 	// if err != nil {
 	// 	return nil, err